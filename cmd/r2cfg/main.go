@@ -0,0 +1,63 @@
+// Утилита для переключения клиента игры между логин-серверами.
+//
+// Меняет channelserverip и channelserverport в R2.cfg, сохраняя остальное содержимое файла:
+//
+//	r2cfg -config "C:\R2\R2.cfg" -host 127.0.0.1 -port 11004
+//
+// Без -host и -port выводит текущий адрес логин-сервера.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/tuxuuman/r2o-core/pkg/r2cfg"
+)
+
+func main() {
+	configPath := flag.String("config", "R2.cfg", "путь к R2.cfg")
+	host := flag.String("host", "", "ip логин-сервера")
+	port := flag.Uint("port", 0, "порт логин-сервера")
+	backup := flag.Bool("backup", false, "сохранить копию исходного файла в <config>.bak")
+	flag.Parse()
+
+	config, err := r2cfg.Load(*configPath)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *host == "" && *port == 0 {
+		ip, _ := config.Get(r2cfg.KEY_CHANNEL_SERVER_IP)
+		p, _ := config.Get(r2cfg.KEY_CHANNEL_SERVER_PORT)
+		fmt.Printf("%v:%v\n", ip, p)
+		return
+	}
+
+	if *host == "" || *port == 0 || *port > 65535 {
+		fmt.Fprintln(os.Stderr, "Нужно указать -host и -port")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *backup {
+		data, err := ioutil.ReadFile(*configPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := ioutil.WriteFile(*configPath+".bak", data, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	config.SetLoginServer(*host, uint16(*port))
+
+	if err := config.Save(*configPath); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Клиент переключен на %v:%v\n", *host, *port)
+}
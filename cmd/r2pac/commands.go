@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+// Разобрать общие параметры команды, которая принимает один аргумент с пакетами
+func parseInput(flags *flag.FlagSet, args []string, argsUsage string) ([]packet.Record, bool, bool) {
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Использование: r2pac %v [параметры] %v\n\n", flags.Name(), argsUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return nil, false, false
+	}
+
+	records, isCapture, err := loadRecords(flags.Arg(0))
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, false, false
+	}
+
+	return records, isCapture, true
+}
+
+// Вывести пакеты в виде подробного дампа
+func runDecode(args []string) int {
+	flags := flag.NewFlagSet("decode", flag.ExitOnError)
+	connId := flags.Uint("conn", 0, "выводить только пакеты соединения с этим id из записи трафика. 0 - все соединения")
+
+	records, isCapture, ok := parseInput(flags, args, "<hex-строка или файл>")
+	if !ok {
+		return 2
+	}
+
+	for i, rec := range records {
+		if *connId != 0 && rec.ConnId != uint32(*connId) {
+			continue
+		}
+
+		p, err := rec.Packet()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		p.Decrypt()
+
+		if isCapture {
+			fmt.Printf("#%v %v %v [%v]\n\n", i+1, rec.Time.Format("2006-01-02 15:04:05.000000"), rec.Direction, rec.ConnId)
+		} else if len(records) > 1 {
+			fmt.Printf("#%v\n\n", i+1)
+		}
+
+		fmt.Printf("%v\n\n", p)
+	}
+
+	return 0
+}
+
+// Зашифровать или расшифровать пакеты
+func runCrypt(name string, encrypt bool) func(args []string) int {
+	return func(args []string) int {
+		flags := flag.NewFlagSet(name, flag.ExitOnError)
+		out := addOutputFlags(flags, FORMAT_HEX)
+
+		records, _, ok := parseInput(flags, args, "<hex-строка или файл>")
+		if !ok {
+			return 2
+		}
+
+		for i := range records {
+			p, err := records[i].Packet()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+
+			if encrypt {
+				p.Encrypt()
+			} else {
+				p.Decrypt()
+			}
+
+			records[i].Data = p.Bytes()
+		}
+
+		if err := out.write(records); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		return 0
+	}
+}
+
+// Создать пакет из id и полей в JSON
+func runBuild(args []string) int {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	id := flags.Uint("id", 0, "id пакета")
+	num := flags.Uint("num", 0, "номер пакета")
+	encrypt := flags.Bool("encrypt", false, "зашифровать пакет")
+	fields := flags.String("fields", "", "поля пакета в JSON, например '{\"AccountId\": 1}'. Структура пакета должна быть известна (см. schemas)")
+	data := flags.String("data", "", "данные пакета в виде hex-строки, если структура пакета не известна")
+	out := addOutputFlags(flags, FORMAT_HEX)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Использование: r2pac build -id <id> [-fields <json> | -data <hex>] [параметры]")
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *id == 0 || *id > 65535 || *num > 255 || flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	doc := packet.Document{
+		Id:        uint16(*id),
+		Num:       uint8(*num),
+		Encrypted: *encrypt,
+		Data:      *data,
+	}
+
+	if *fields != "" {
+		d := json.NewDecoder(bytes.NewReader([]byte(*fields)))
+		d.UseNumber()
+		if err := d.Decode(&doc.Fields); err != nil {
+			fmt.Fprintf(os.Stderr, "Некорректный JSON полей: %v\n", err)
+			return 2
+		}
+	}
+
+	p, err := doc.Packet(packet.DefaultRegistry)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if err := out.write(packetRecords([]*packet.Packet{p})); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// Преобразовать пакеты в другой формат
+func runConvert(args []string) int {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	out := addOutputFlags(flags, FORMAT_JSON)
+
+	records, _, ok := parseInput(flags, args, "<hex-строка или файл>")
+	if !ok {
+		return 2
+	}
+
+	if err := out.write(records); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// Вывести список известных пакетов
+func runSchemas(args []string) int {
+	flags := flag.NewFlagSet("schemas", flag.ExitOnError)
+	flags.Parse(args)
+
+	fmt.Printf("%-6v    %-30v    %-4v    %v\n", "ID", "Name", "Dir", "Type")
+
+	for _, schema := range packet.DefaultRegistry.Schemas() {
+		typeName := "-"
+		if schema.Type != nil {
+			typeName = schema.Type.String()
+		}
+		fmt.Printf("%-6v    %-30v    %-4v    %v\n", schema.Id, schema.Name, schema.Direction, typeName)
+	}
+
+	return 0
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+// Сравнить два пакета или две сессии. Возвращает 0, если отличий нет, и 1, если есть
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	connA := flags.Uint("conn-a", 0, "id соединения в записи трафика A. 0 - первое соединение")
+	connB := flags.Uint("conn-b", 0, "id соединения в записи трафика B. 0 - первое соединение")
+	all := flags.Bool("all", false, "выводить и совпадающие пакеты")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Использование: r2pac diff [параметры] <A> <B>")
+		fmt.Fprintln(os.Stderr, "\nA и B - hex-строки или файлы с пакетами. Для нескольких пакетов они сопоставляются по id.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	a, err := loadPackets(flags.Arg(0), uint32(*connA))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	b, err := loadPackets(flags.Arg(1), uint32(*connB))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if len(a) == 1 && len(b) == 1 {
+		diff := packet.Diff(a[0], b[0])
+		fmt.Print(diff)
+		if diff.Equal() {
+			return 0
+		}
+		return 1
+	}
+
+	diffs := packet.DefaultRegistry.DiffSequences(a, b)
+	different := 0
+
+	for i, diff := range diffs {
+		if diff.Equal() {
+			if *all {
+				fmt.Printf("#%v %v: совпадает\n", i+1, packet.DefaultRegistry.Name(diff.A.Id))
+			}
+			continue
+		}
+
+		different += 1
+
+		if diff.A == nil || diff.B == nil {
+			fmt.Printf("#%v %v", i+1, diff)
+			continue
+		}
+
+		fmt.Printf("\n#%v ---------------------------------------------\n%v\n", i+1, diff)
+	}
+
+	fmt.Printf("\nПакетов: A %v, B %v. Отличается: %v\n", len(a), len(b), different)
+
+	if different > 0 {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+	"gopkg.in/yaml.v3"
+)
+
+// Прочитать пакеты, идущие друг за другом, как из сетевого соединения
+func readPackets(b []byte) ([]*packet.Packet, error) {
+	r := bytes.NewReader(b)
+	result := []*packet.Packet{}
+
+	for {
+		p, err := packet.ReadPacket(r)
+
+		if err == io.EOF {
+			return result, nil
+		}
+
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Не удалось прочитать пакет #%v: %v", len(result)+1, err))
+		}
+
+		result = append(result, p)
+	}
+}
+
+// Прочитать пакеты из JSON или YAML: один документ пакета (см. packet.Document) или список документов
+func readDocuments(b []byte, isYAML bool) ([]*packet.Packet, error) {
+	list := []*packet.Packet{}
+	single := &packet.Packet{}
+
+	if isYAML {
+		if err := yaml.Unmarshal(b, &list); err == nil {
+			return list, nil
+		}
+		if err := yaml.Unmarshal(b, single); err != nil {
+			return nil, err
+		}
+		return []*packet.Packet{single}, nil
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")) {
+		if err := json.Unmarshal(b, &list); err != nil {
+			return nil, err
+		}
+		return list, nil
+	}
+
+	if err := json.Unmarshal(b, single); err != nil {
+		return nil, err
+	}
+
+	return []*packet.Packet{single}, nil
+}
+
+// Представить пакеты записями без времени, направления и соединения
+func packetRecords(packets []*packet.Packet) []packet.Record {
+	records := make([]packet.Record, len(packets))
+
+	for i, p := range packets {
+		records[i] = packet.Record{Data: p.Bytes()}
+	}
+
+	return records
+}
+
+// Прочитать пакеты из аргумента командной строки.
+//
+// "arg" - путь к файлу или hex-строка (пробелы игнорируются). Файлы с сигнатурой записи трафика читаются как запись трафика,
+// .json, .yaml и .yml - как документы пакетов, остальные файлы (в том числе .r2pac без сигнатуры, например resources/acp.r2pac) -
+// как байты пакетов, идущие друг за другом.
+//
+// Возвращает записи и признак того, что они прочитаны из записи трафика (только у них есть время, направление и соединение).
+func loadRecords(arg string) ([]packet.Record, bool, error) {
+	if _, err := os.Stat(arg); err != nil {
+		b, hexErr := hex.DecodeString(strings.Join(strings.Fields(arg), ""))
+
+		if hexErr != nil {
+			return nil, false, errors.New(fmt.Sprintf("%v не является ни файлом, ни hex-строкой", arg))
+		}
+
+		packets, err := readPackets(b)
+
+		return packetRecords(packets), false, err
+	}
+
+	b, err := ioutil.ReadFile(arg)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if packet.IsCapture(b) {
+		cr, err := packet.CreateCaptureReader(bytes.NewReader(b))
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		records, err := cr.ReadAll()
+
+		return records, true, err
+	}
+
+	var packets []*packet.Packet
+
+	switch strings.ToLower(filepath.Ext(arg)) {
+	case ".json":
+		packets, err = readDocuments(b, false)
+	case ".yaml", ".yml":
+		packets, err = readDocuments(b, true)
+	default:
+		packets, err = readPackets(b)
+	}
+
+	return packetRecords(packets), false, err
+}
+
+// Выбрать записи одного соединения. 0 - первое соединение
+func connectionRecords(records []packet.Record, connId uint32) []packet.Record {
+	result := []packet.Record{}
+
+	for _, rec := range records {
+		if connId == 0 {
+			connId = rec.ConnId
+		}
+
+		if rec.ConnId == connId {
+			result = append(result, rec)
+		}
+	}
+
+	return result
+}
+
+// Создать пакеты из записей
+func recordPackets(records []packet.Record) ([]*packet.Packet, error) {
+	result := make([]*packet.Packet, len(records))
+
+	for i, rec := range records {
+		p, err := rec.Packet()
+
+		if err != nil {
+			return nil, err
+		}
+
+		result[i] = p
+	}
+
+	return result, nil
+}
+
+// Прочитать пакеты одного соединения (см. loadRecords)
+//
+// "connId" - id соединения, пакеты которого нужно взять из записи трафика. 0 - первое соединение
+func loadPackets(arg string, connId uint32) ([]*packet.Packet, error) {
+	records, isCapture, err := loadRecords(arg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if isCapture {
+		records = connectionRecords(records, connId)
+	}
+
+	return recordPackets(records)
+}
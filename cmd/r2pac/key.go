@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+// Сколько противоречий выводить
+const MAX_PRINTED_CONFLICTS = 10
+
+// Восстановить ключ шифрования по зашифрованным пакетам и их известному содержимому
+func runKey(args []string) int {
+	flags := flag.NewFlagSet("key", flag.ExitOnError)
+	base := flags.String("base", "current", "исходный ключ, который дополняется: current - текущий ключ, none - пустой ключ, или путь к файлу ключа")
+	connEncrypted := flags.Uint("conn-encrypted", 0, "id соединения в записи трафика зашифрованных пакетов. 0 - первое соединение")
+	connPlain := flags.Uint("conn-plain", 0, "id соединения в записи трафика известных пакетов. 0 - первое соединение")
+	minMatches := flags.Int("min-matches", 64, "минимальное кол-во совпавших пар байт для обнаружения периода ключа")
+	extend := flags.Int("extend", 0, "дополнить ключ данных по найденному периоду до этой длинны")
+	out := flags.String("o", "", "файл, в который будет записан ключ. Если не указан, то ключ не записывается")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Использование: r2pac key [параметры] <зашифрованные пакеты> <известные пакеты>")
+		fmt.Fprintln(os.Stderr, "\nЗашифрованные пакеты - в том виде, в котором они были переданы (например запись трафика .r2pac).")
+		fmt.Fprintln(os.Stderr, "Известные пакеты - те же пакеты в том же порядке с известным содержимым (например документы .json/.yaml).")
+		fmt.Fprintln(os.Stderr, "Ключ восстанавливается как XOR зашифрованных и известных байт.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	var key *packet.CryptKey
+	var err error
+
+	switch *base {
+	case "current":
+		key = packet.CurrentCryptKey()
+	case "none":
+		key = packet.CreateCryptKey()
+	default:
+		key, err = packet.ReadCryptKeyFile(*base)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	records, _, err := loadRecords(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	// байты зашифрованных пакетов нужны в исходном виде, поэтому пакеты из них не создаются
+	records = connectionRecords(records, uint32(*connEncrypted))
+
+	plain, err := loadPackets(flags.Arg(1), uint32(*connPlain))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if len(records) != len(plain) {
+		fmt.Fprintf(os.Stderr, "Кол-во зашифрованных пакетов [%v] не совпадает с кол-вом известных [%v], сопоставлены первые пакеты\n", len(records), len(plain))
+	}
+
+	before := key.Length()
+	used := 0
+	conflicts := []packet.KeyConflict{}
+
+	for i := 0; i < len(records) && i < len(plain); i++ {
+		c, err := key.AddPacket(records[i].Data, plain[i])
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "#%v %v: %v, пропущен\n", i+1, packet.DefaultRegistry.Name(plain[i].Id), err)
+			continue
+		}
+
+		used += 1
+		conflicts = append(conflicts, c...)
+	}
+
+	fmt.Printf("Использовано пар пакетов: %v\n", used)
+	fmt.Printf("Длинна ключа: %v -> %v байт\n", before, key.Length())
+	fmt.Printf("Известно с начала без пропусков: %v байт, не известно: %v байт\n", key.KnownPrefix(), key.Unknown())
+
+	if len(conflicts) > 0 {
+		fmt.Printf("\nПротиворечий: %v. Известное содержимое не соответствует пакетам или исходный ключ не верен\n", len(conflicts))
+		for i, c := range conflicts {
+			if i == MAX_PRINTED_CONFLICTS {
+				fmt.Println("  ...")
+				break
+			}
+			fmt.Printf("  %v\n", c)
+		}
+	}
+
+	period := key.Period(*minMatches)
+
+	if period > 0 {
+		fmt.Printf("\nПериод ключа данных: %v байт\n", period)
+	} else {
+		fmt.Println("\nПериод ключа данных не найден")
+	}
+
+	if *extend > 0 {
+		if period == 0 {
+			fmt.Fprintln(os.Stderr, "Ключ нельзя дополнить: период не найден")
+			return 1
+		}
+		restored := key.ExtendPeriodic(period, *extend)
+		fmt.Printf("Дополнено по периоду: %v байт, длинна ключа %v байт\n", restored, key.Length())
+	}
+
+	if *out != "" {
+		if err := key.WriteFile(*out); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Ключ записан в %v\n", *out)
+	}
+
+	return 0
+}
@@ -0,0 +1,85 @@
+// Утилита для работы с пакетами R2.
+//
+//	r2pac decode <пакеты>                       вывести пакеты в расшифрованном виде с разбором полей
+//	r2pac encrypt <пакеты>                      зашифровать пакеты
+//	r2pac decrypt <пакеты>                      расшифровать пакеты
+//	r2pac build -id 3100 -fields '{...}'        создать пакет из id и полей в JSON
+//	r2pac convert -to yaml <пакеты>             преобразовать пакеты в hex, bin, json, yaml или r2pac
+//	r2pac schemas                               вывести список известных пакетов
+//	r2pac diff <A> <B>                          сравнить два пакета или две сессии
+//	r2pac key -o new.key <зашифр.> <известные>  восстановить ключ шифрования по пакетам с известным содержимым
+//
+// Пакеты можно указать hex-строкой или файлом: запись трафика .r2pac, документы пакетов .json/.yaml или файл с байтами пакетов.
+//
+// Параметр -key перед командой заменяет ключ шифрования пакетов ключом из файла, например восстановленным командой key:
+//
+//	r2pac -key new.key decode session.r2pac
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+
+	// описания пакетов библиотеки регистрируются в packet.DefaultRegistry при импорте
+	_ "github.com/tuxuuman/r2o-core/pkg/login"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) int
+}
+
+var commands = []command{
+	{"decode", "вывести пакеты в расшифрованном виде с разбором полей", runDecode},
+	{"encrypt", "зашифровать пакеты", runCrypt("encrypt", true)},
+	{"decrypt", "расшифровать пакеты", runCrypt("decrypt", false)},
+	{"build", "создать пакет из id и полей в JSON", runBuild},
+	{"convert", "преобразовать пакеты в другой формат", runConvert},
+	{"schemas", "вывести список известных пакетов", runSchemas},
+	{"diff", "сравнить два пакета или две сессии", runDiff},
+	{"key", "восстановить ключ шифрования по пакетам с известным содержимым", runKey},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Использование: r2pac [-key <файл ключа>] <команда> [параметры]")
+	fmt.Fprintln(os.Stderr)
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10v %v\n", c.name, c.usage)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Параметры команды: r2pac <команда> -h")
+}
+
+func main() {
+	keyPath := flag.String("key", "", "файл ключа шифрования пакетов, который будет использован вместо встроенного")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	if *keyPath != "" {
+		key, err := packet.ReadCryptKeyFile(*keyPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		packet.SetCryptKey(key)
+	}
+
+	for _, c := range commands {
+		if c.name == flag.Arg(0) {
+			os.Exit(c.run(flag.Args()[1:]))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Неизвестная команда %v\n\n", flag.Arg(0))
+	usage()
+	os.Exit(2)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+	"gopkg.in/yaml.v3"
+)
+
+// Форматы вывода пакетов
+const (
+	FORMAT_HEX   = "hex"
+	FORMAT_BIN   = "bin"
+	FORMAT_JSON  = "json"
+	FORMAT_YAML  = "yaml"
+	FORMAT_R2PAC = "r2pac"
+)
+
+// Параметры вывода пакетов
+type output struct {
+	format *string
+	path   *string
+}
+
+func addOutputFlags(flags *flag.FlagSet, defaultFormat string) output {
+	return output{
+		format: flags.String("to", defaultFormat, "формат вывода: hex, bin, json, yaml или r2pac"),
+		path:   flags.String("o", "", "файл для вывода. По умолчанию stdout"),
+	}
+}
+
+// Записать пакеты в выбранном формате. Записи без соединения (не из записи трафика) пишутся в r2pac как соединение 1
+func (this *output) write(records []packet.Record) error {
+	switch *this.format {
+	case FORMAT_HEX, FORMAT_BIN, FORMAT_JSON, FORMAT_YAML, FORMAT_R2PAC:
+	default:
+		return errors.New(fmt.Sprintf("Неизвестный формат вывода %v", *this.format))
+	}
+
+	var w io.Writer = os.Stdout
+
+	if *this.path != "" {
+		f, err := os.Create(*this.path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	} else if *this.format == FORMAT_R2PAC {
+		return errors.New("Для вывода в r2pac нужно указать файл (-o)")
+	}
+
+	if *this.format == FORMAT_R2PAC {
+		capture, err := packet.CreateCaptureWriter(w)
+		if err != nil {
+			return err
+		}
+
+		for _, rec := range records {
+			if rec.ConnId == 0 {
+				rec.ConnId = 1
+				rec.Time = time.Now()
+				if p, err := rec.Packet(); err == nil {
+					schema, _ := packet.DefaultRegistry.Lookup(p.Id)
+					rec.Direction = schema.Direction
+				}
+			}
+
+			if err := capture.Write(rec); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	packets, err := recordPackets(records)
+
+	if err != nil {
+		return err
+	}
+
+	switch *this.format {
+	case FORMAT_HEX:
+		for _, p := range packets {
+			if _, err := fmt.Fprintln(w, p.Hex()); err != nil {
+				return err
+			}
+		}
+	case FORMAT_BIN:
+		for _, p := range packets {
+			if _, err := w.Write(p.Bytes()); err != nil {
+				return err
+			}
+		}
+	case FORMAT_JSON:
+		var v interface{} = packets
+		if len(packets) == 1 {
+			v = packets[0]
+		}
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case FORMAT_YAML:
+		var v interface{} = packets
+		if len(packets) == 1 {
+			v = packets[0]
+		}
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+// Утилита для воспроизведения записанной сессии клиента на работающем сервере.
+//
+// Отправляет серверу пакеты клиента из записи трафика .r2pac и сравнивает ответы сервера с записанными:
+//
+//	r2replay -addr 127.0.0.1:11004 -scale 0 session.r2pac
+//
+// Завершается с кодом 1, если ответы сервера отличаются от записи.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/tuxuuman/r2o-core/pkg/replay"
+)
+
+func main() {
+	options := replay.DefaultOptions()
+
+	addr := flag.String("addr", "127.0.0.1:11004", "адрес сервера")
+	connId := flag.Uint("conn", 0, "id соединения из записи. 0 - первое соединение")
+	flag.Float64Var(&options.TimeScale, "scale", options.TimeScale, "множитель задержек между пакетами. 0 - без задержек")
+	flag.BoolVar(&options.WaitResponses, "wait", options.WaitResponses, "ждать ответы сервера перед отправкой следующего пакета")
+	flag.DurationVar(&options.ResponseTimeout, "timeout", options.ResponseTimeout, "максимальное время ожидания ответов")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Нужно указать файл записи трафика")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	options.ConnId = uint32(*connId)
+
+	report, err := replay.RunFile(*addr, flag.Arg(0), options)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Print(report)
+
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
@@ -83,7 +83,7 @@ func (emitter *Emitter) Emit(evtType interface{}, evtData ...interface{}) {
 			if listener.once {
 				defer listener.off()
 			}
-			listener.cb()
+			listener.cb(evtData...)
 		}
 	}
 }
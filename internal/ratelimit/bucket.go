@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Потоко-безопасный "token bucket".
+//
+// Корзина пополняется на "rate" токенов в секунду и вмещает не более "burst" токенов. Каждое действие забирает один токен.
+type TokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+func (this *TokenBucket) refill(now time.Time) {
+	this.tokens += now.Sub(this.last).Seconds() * this.rate
+	if this.tokens > this.burst {
+		this.tokens = this.burst
+	}
+	this.last = now
+}
+
+// Забрать токен. Возвращает false если токенов нет и действие нужно ограничить.
+func (this *TokenBucket) Allow() bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.refill(time.Now())
+
+	if this.tokens < 1 {
+		return false
+	}
+
+	this.tokens -= 1
+
+	return true
+}
+
+// Есть ли в корзине токен. В отличие от Allow токен не забирается.
+func (this *TokenBucket) HasToken() bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.refill(time.Now())
+
+	return this.tokens >= 1
+}
+
+// Полностью ли заполнена корзина. Полную корзину можно удалить без изменения поведения ограничения.
+func (this *TokenBucket) IsFull() bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.refill(time.Now())
+
+	return this.tokens >= this.burst
+}
+
+// Создает корзину.
+//
+// "rate" - кол-во токенов в секунду
+//
+// "burst" - максимальное кол-во токенов. Если меньше 1, то будет равен 1
+func CreateTokenBucket(rate float64, burst uint16) *TokenBucket {
+	b := float64(burst)
+	if b < 1 {
+		b = 1
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  b,
+		tokens: b,
+		last:   time.Now(),
+	}
+}
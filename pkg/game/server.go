@@ -0,0 +1,145 @@
+package game
+
+import (
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/login"
+	"github.com/tuxuuman/r2o-core/pkg/net"
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+	"github.com/tuxuuman/r2o-core/pkg/session"
+)
+
+const (
+	// Id первого пакета клиента с id сессии по умолчанию. Настоящий id пока не известен: предполагается, что клиент игры
+	// начинает соединение с игровым сервером так же, как с логин-сервером (3100). Если это не так, укажите HandshakePacketId
+	PACKET_HANDSHAKE uint16 = 3100
+)
+
+// Клиент игрового сервера, прошедший проверку сессии
+type Client struct {
+	*net.Client
+	// Сессия, выданная клиенту логин-сервером
+	Session session.Session
+}
+
+// Прочитать id сессии из первого пакета клиента. По умолчанию id сессии - первые 4 байта пакета
+func DecodeSessionId(p *packet.Packet) (uint32, error) {
+	var sessionId uint32
+	err := p.Read(&sessionId)
+	return sessionId, err
+}
+
+// Каркас игрового сервера.
+//
+// Принимает подключение клиента, ждет от него пакет с id сессии, выданным логин-сервером (3101),
+// проверяет сессию через хранилище сессий и передает авторизованного клиента в OnClient.
+type Server struct {
+	net.Server
+	// Id этого игрового сервера в списке серверов логин-сервера
+	ServerId uint16
+	// Хранилище сессий, общее с логин-сервером
+	Sessions session.Store
+	// Id первого пакета клиента, в котором он передает id сессии. Клиент, первым приславший другой пакет, отключается
+	// с ошибкой InvalidSessionErrorId (По умолчанию: PACKET_HANDSHAKE).
+	HandshakePacketId uint16
+	// Чтение id сессии из пакета HandshakePacketId (По умолчанию: DecodeSessionId)
+	DecodeHandshake func(p *packet.Packet) (sessionId uint32, err error)
+	// Максимальное время ожидания пакета с id сессии после принятия подключения (По умолчанию: 10 сек)
+	HandshakeTimeout time.Duration
+	// Id ошибки, отправляемой клиенту с неверной сессией или не приславшему id сессии первым пакетом (По умолчанию: login.ERROR_INVALID_SESSION)
+	InvalidSessionErrorId uint32
+	// Вызывается для каждого клиента, прошедшего проверку сессии. Тут устанавливаются обработчики игровых пакетов
+	OnClient func(c *Client)
+}
+
+// Запустить игровой сервер
+func (this *Server) Start() {
+	if this.HandshakePacketId == 0 {
+		panic(errors.New("Не указан id пакета с id сессии (HandshakePacketId)"))
+	}
+
+	if this.Sessions == nil {
+		panic(errors.New("Не указано хранилище сессий (Sessions)"))
+	}
+
+	this.Server.Start(this.handleConnection)
+}
+
+func (this *Server) handleConnection(c *net.Client) {
+	var authenticated int32
+
+	// до проверки сессии клиент может прислать только пакет с id сессии, иначе игровые пакеты можно было бы отправлять без авторизации
+	c.OnPacket(func(p *packet.Packet) {
+		if p.Id != this.HandshakePacketId && atomic.LoadInt32(&authenticated) == 0 {
+			log.Printf("Клиент %v прислал пакет [%v] до пакета с id сессии", c.IP(), packet.DefaultRegistry.Name(p.Id))
+			c.FatalError(this.InvalidSessionErrorId)
+			c.Close()
+		}
+	}, false)
+
+	c.SetPacketHandler(this.HandshakePacketId, func(p *packet.Packet, data interface{}) {
+		sessionId, err := this.DecodeHandshake(p)
+
+		if err != nil {
+			log.Printf("Не удалось прочитать id сессии клиента %v: %v", c.IP(), err)
+			c.FatalError(this.InvalidSessionErrorId)
+			c.Close()
+			return
+		}
+
+		s, err := this.Sessions.Consume(sessionId, c.IP(), this.ServerId)
+
+		if err != nil {
+			log.Printf("Клиент %v не прошел проверку сессии [%v]: %v", c.IP(), sessionId, err)
+			c.FatalError(this.InvalidSessionErrorId)
+			c.Close()
+			return
+		}
+
+		c.SetAccountId(s.AccountId)
+
+		if !this.IsAllowedDuringMaintenance(c) {
+			log.Printf("Клиент %v [%v] не допущен во время технических работ", c.IP(), s.AccountId)
+			c.FatalError(this.MaintenanceErrorId)
+			c.Close()
+			return
+		}
+
+		atomic.StoreInt32(&authenticated, 1)
+		log.Printf("Клиент %v подключился к игровому серверу. Аккаунт: %v", c.IP(), s.AccountId)
+
+		if this.OnClient != nil {
+			this.OnClient(&Client{Client: c, Session: s})
+		}
+	}, nil, true)
+
+	c.Accept()
+
+	go func() {
+		time.Sleep(this.HandshakeTimeout)
+		if atomic.LoadInt32(&authenticated) == 0 {
+			log.Printf("Клиент %v не прислал id сессии за %v", c.IP(), this.HandshakeTimeout)
+			c.Close()
+		}
+	}()
+}
+
+// Создает игровой сервер
+//
+// "serverId" - id этого игрового сервера в списке серверов логин-сервера
+//
+// "sessions" - хранилище сессий, общее с логин-сервером
+func CreateServer(host string, port uint16, serverId uint16, sessions session.Store) *Server {
+	return &Server{
+		Server:                net.CreateServer(host, port),
+		ServerId:              serverId,
+		Sessions:              sessions,
+		HandshakePacketId:     PACKET_HANDSHAKE,
+		DecodeHandshake:       DecodeSessionId,
+		HandshakeTimeout:      time.Second * 10,
+		InvalidSessionErrorId: login.ERROR_INVALID_SESSION,
+	}
+}
@@ -0,0 +1,174 @@
+package launcher
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Максимальная длинна логина (P0). Столько байт отведено под него в пакете подключения к игровому серверу
+	LOGIN_MAX_LENGTH = 20
+	// Максимальная длинна токена авторизации (P4), которую читает логин-сервер из пакета авторизации.
+	// На самом деле клиент может передать гораздо больше (2000+ байт)
+	TOKEN_MAX_LENGTH = 64
+)
+
+const (
+	// Значения P1 и P2 из стандартных параметров запуска
+	DEFAULT_P1 = "C_R2"
+	DEFAULT_P2 = "4612"
+)
+
+// Параметры запуска клиента игры (R2ClientRU.exe).
+//
+// Передаются одним аргументом в виде строки запроса, значения которой закодированы в base64:
+//
+//	P0=cXdlcnR5&P1=Q19SMg==&P2=NDYxMg==&P4=cXdlcnR5&PC1=Tg==&PC2=Tg==
+type Params struct {
+	// P0. Логин игрока
+	Login string
+	// P1. Назначение не известно, обычно "C_R2"
+	P1 string
+	// P2. Назначение не известно, обычно "4612"
+	P2 string
+	// P4. Токен авторизации, который клиент передает логин-серверу
+	Token string
+	// PC1. Флаг Y/N, назначение не известно
+	PC1 bool
+	// PC2. Флаг Y/N, назначение не известно
+	PC2 bool
+	// Прочие параметры, не известные этому пакету. Сохраняются при разборе и сборке строки
+	Extra []Param
+}
+
+// Параметр запуска
+type Param struct {
+	Key   string
+	Value string
+}
+
+func encodeFlag(flag bool) string {
+	if flag {
+		return "Y"
+	}
+	return "N"
+}
+
+func decodeFlag(key string, value string) (bool, error) {
+	switch value {
+	case "Y":
+		return true, nil
+	case "N":
+		return false, nil
+	default:
+		return false, errors.New(fmt.Sprintf("Некорректное значение флага %v [%v]. Допустимые значения: Y, N", key, value))
+	}
+}
+
+// Проверить параметры
+func (this *Params) Validate() error {
+	if this.Login == "" {
+		return errors.New("Не указан логин (P0)")
+	}
+
+	if len(this.Login) > LOGIN_MAX_LENGTH {
+		return errors.New(fmt.Sprintf("Длинна логина (P0) [%v] превышает допустимую [%v]", len(this.Login), LOGIN_MAX_LENGTH))
+	}
+
+	if this.Token == "" {
+		return errors.New("Не указан токен (P4)")
+	}
+
+	if len(this.Token) > TOKEN_MAX_LENGTH {
+		return errors.New(fmt.Sprintf("Длинна токена (P4) [%v] превышает допустимую [%v]", len(this.Token), TOKEN_MAX_LENGTH))
+	}
+
+	return nil
+}
+
+// Представить параметры в виде строки для передачи клиенту игры
+func (this *Params) String() string {
+	params := []Param{
+		{"P0", this.Login},
+		{"P1", this.P1},
+		{"P2", this.P2},
+		{"P4", this.Token},
+		{"PC1", encodeFlag(this.PC1)},
+		{"PC2", encodeFlag(this.PC2)},
+	}
+
+	params = append(params, this.Extra...)
+	parts := make([]string, len(params))
+
+	for i, p := range params {
+		parts[i] = p.Key + "=" + base64.StdEncoding.EncodeToString([]byte(p.Value))
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// Создает параметры запуска со стандартными значениями P1, P2, PC1, PC2
+//
+// "login" - логин игрока
+//
+// "token" - токен авторизации
+func CreateParams(login string, token string) Params {
+	return Params{
+		Login: login,
+		P1:    DEFAULT_P1,
+		P2:    DEFAULT_P2,
+		Token: token,
+	}
+}
+
+// Разобрать строку параметров запуска и проверить их
+func Parse(str string) (Params, error) {
+	params := Params{}
+	str = strings.Trim(strings.TrimSpace(str), "\"")
+
+	for _, part := range strings.Split(str, "&") {
+		if part == "" {
+			continue
+		}
+
+		kv := strings.SplitN(part, "=", 2)
+
+		if len(kv) != 2 {
+			return params, errors.New(fmt.Sprintf("Некорректный параметр запуска [%v]", part))
+		}
+
+		key := kv[0]
+		b, err := base64.StdEncoding.DecodeString(kv[1])
+
+		if err != nil {
+			return params, errors.New(fmt.Sprintf("Не удалось декодировать значение параметра %v: %v", key, err))
+		}
+
+		value := string(b)
+
+		switch key {
+		case "P0":
+			params.Login = value
+		case "P1":
+			params.P1 = value
+		case "P2":
+			params.P2 = value
+		case "P4":
+			params.Token = value
+		case "PC1":
+			if params.PC1, err = decodeFlag(key, value); err != nil {
+				return params, err
+			}
+		case "PC2":
+			if params.PC2, err = decodeFlag(key, value); err != nil {
+				return params, err
+			}
+		default:
+			params.Extra = append(params.Extra, Param{key, value})
+		}
+	}
+
+	return params, params.Validate()
+}
@@ -0,0 +1,283 @@
+package login
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Сгенерировать случайный, не нулевой id сессии
+func GenerateSessionId() uint32 {
+	b := make([]byte, 4)
+
+	for {
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+		if id := binary.LittleEndian.Uint32(b); id != 0 {
+			return id
+		}
+	}
+}
+
+// Авторизация по фиксированному списку токенов. Подходит для тестов.
+type StaticAuthenticator struct {
+	accounts map[string]uint32
+	mu       sync.RWMutex
+	// Id ошибки при неизвестном токене (По умолчанию: ERROR_INVALID_SESSION)
+	ErrorId uint32
+}
+
+// Добавить или заменить токен аккаунта
+func (this *StaticAuthenticator) Set(token string, accountId uint32) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.accounts[token] = accountId
+}
+
+// Удалить токен
+func (this *StaticAuthenticator) Remove(token string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	delete(this.accounts, token)
+}
+
+func (this *StaticAuthenticator) Authenticate(token string, ip string) (uint32, uint32, uint32) {
+	this.mu.RLock()
+	accountId, exists := this.accounts[token]
+	this.mu.RUnlock()
+
+	if !exists {
+		return 0, 0, this.ErrorId
+	}
+
+	return accountId, GenerateSessionId(), 0
+}
+
+// Создает авторизацию по фиксированному списку токенов
+//
+// "accounts" - токены и соответствующие им id аккаунтов
+func CreateStaticAuthenticator(accounts map[string]uint32) *StaticAuthenticator {
+	auth := &StaticAuthenticator{
+		accounts: make(map[string]uint32, len(accounts)),
+		ErrorId:  ERROR_INVALID_SESSION,
+	}
+
+	for token, accountId := range accounts {
+		auth.accounts[token] = accountId
+	}
+
+	return auth
+}
+
+// Аккаунт в json файле авторизации
+type JSONAccount struct {
+	// Токен авторизации (параметр запуска P4)
+	Token string `json:"token"`
+	// Id аккаунта
+	AccountId uint32 `json:"accountId"`
+	// Список IP с которых разрешена авторизация. Пустой список - с любых IP
+	IPs []string `json:"ips,omitempty"`
+	// Заблокирован ли аккаунт
+	Blocked bool `json:"blocked,omitempty"`
+}
+
+// Авторизация по списку аккаунтов, хранящемуся в локальном json файле. Файл перечитывается при изменении.
+//
+// Формат файла:
+//
+//	[
+//		{"token": "qwerty", "accountId": 1, "ips": ["127.0.0.1"]},
+//		{"token": "asdfgh", "accountId": 2, "blocked": true}
+//	]
+type JSONAuthenticator struct {
+	path     string
+	accounts map[string]JSONAccount
+	modTime  time.Time
+	mu       sync.Mutex
+	// Id ошибки при неизвестном токене или неразрешенном IP (По умолчанию: ERROR_INVALID_SESSION)
+	ErrorId uint32
+	// Id ошибки для заблокированного аккаунта (По умолчанию: ERROR_INVALID_SESSION)
+	BlockedErrorId uint32
+}
+
+func (this *JSONAuthenticator) reloadIfChanged() error {
+	info, err := os.Stat(this.path)
+
+	if err != nil {
+		return err
+	}
+
+	if info.ModTime().Equal(this.modTime) {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(this.path)
+
+	if err != nil {
+		return err
+	}
+
+	list := []JSONAccount{}
+
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	accounts := make(map[string]JSONAccount, len(list))
+
+	for _, account := range list {
+		if account.Token == "" {
+			return errors.New(fmt.Sprintf("Пустой токен у аккаунта [%v]", account.AccountId))
+		}
+		accounts[account.Token] = account
+	}
+
+	this.accounts = accounts
+	this.modTime = info.ModTime()
+
+	return nil
+}
+
+func (this *JSONAuthenticator) Authenticate(token string, ip string) (uint32, uint32, uint32) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if err := this.reloadIfChanged(); err != nil {
+		// продолжаем работать с ранее загруженным списком
+		log.Printf("Не удалось перечитать файл авторизации [%v]: %v", this.path, err)
+	}
+
+	account, exists := this.accounts[token]
+
+	if !exists {
+		return 0, 0, this.ErrorId
+	}
+
+	if account.Blocked {
+		return 0, 0, this.BlockedErrorId
+	}
+
+	if len(account.IPs) > 0 {
+		allowed := false
+		for _, allowedIp := range account.IPs {
+			if allowedIp == ip {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return 0, 0, this.ErrorId
+		}
+	}
+
+	return account.AccountId, GenerateSessionId(), 0
+}
+
+// Создает авторизацию по списку аккаунтов из json файла "path"
+func CreateJSONAuthenticator(path string) (*JSONAuthenticator, error) {
+	auth := &JSONAuthenticator{
+		path:           path,
+		ErrorId:        ERROR_INVALID_SESSION,
+		BlockedErrorId: ERROR_INVALID_SESSION,
+	}
+
+	if err := auth.reloadIfChanged(); err != nil {
+		return nil, err
+	}
+
+	return auth, nil
+}
+
+// Запрос, передаваемый в stdin команды авторизации
+type CommandAuthRequest struct {
+	Token string `json:"token"`
+	IP    string `json:"ip"`
+}
+
+// Ответ, ожидаемый в stdout от команды авторизации
+type CommandAuthResponse struct {
+	AccountId uint32 `json:"accountId"`
+	// Если 0, то id сессии будет сгенерирован автоматически
+	SessionId uint32 `json:"sessionId,omitempty"`
+	// Если не 0, то авторизация не удалась
+	ErrorId uint32 `json:"errorId,omitempty"`
+}
+
+// Авторизация через вызов локального исполняемого файла (скрипта), например для проверки токена в своей базе данных.
+//
+// Команде в stdin передается CommandAuthRequest в виде json, в stdout она должна вывести CommandAuthResponse в виде json.
+// Если команда завершилась с ошибкой, не уложилась в Timeout или вывела некорректный ответ, то клиенту отправляется ErrorId.
+type CommandAuthenticator struct {
+	// Путь к исполняемому файлу
+	Path string
+	// Аргументы командной строки
+	Args []string
+	// Максимальное время выполнения команды (По умолчанию: 5 сек)
+	Timeout time.Duration
+	// Id ошибки при сбое команды (По умолчанию: ERROR_INVALID_SESSION)
+	ErrorId uint32
+}
+
+func (this *CommandAuthenticator) Authenticate(token string, ip string) (uint32, uint32, uint32) {
+	ctx, cancel := context.WithTimeout(context.Background(), this.Timeout)
+	defer cancel()
+
+	input, err := json.Marshal(CommandAuthRequest{Token: token, IP: ip})
+
+	if err != nil {
+		log.Printf("Не удалось подготовить запрос авторизации: %v", err)
+		return 0, 0, this.ErrorId
+	}
+
+	cmd := exec.CommandContext(ctx, this.Path, this.Args...)
+	cmd.Stdin = strings.NewReader(string(input))
+	output, err := cmd.Output()
+
+	if err != nil {
+		log.Printf("Команда авторизации [%v] завершилась с ошибкой: %v", this.Path, err)
+		return 0, 0, this.ErrorId
+	}
+
+	res := CommandAuthResponse{}
+
+	if err := json.Unmarshal(output, &res); err != nil {
+		log.Printf("Команда авторизации [%v] вернула некорректный ответ: %v", this.Path, err)
+		return 0, 0, this.ErrorId
+	}
+
+	if res.ErrorId != 0 {
+		return 0, 0, res.ErrorId
+	}
+
+	if res.AccountId == 0 {
+		log.Printf("Команда авторизации [%v] не вернула id аккаунта", this.Path)
+		return 0, 0, this.ErrorId
+	}
+
+	if res.SessionId == 0 {
+		res.SessionId = GenerateSessionId()
+	}
+
+	return res.AccountId, res.SessionId, 0
+}
+
+// Создает авторизацию через вызов локального исполняемого файла "path" с аргументами "args"
+func CreateCommandAuthenticator(path string, args ...string) *CommandAuthenticator {
+	return &CommandAuthenticator{
+		Path:    path,
+		Args:    args,
+		Timeout: time.Second * 5,
+		ErrorId: ERROR_INVALID_SESSION,
+	}
+}
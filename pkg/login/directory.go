@@ -0,0 +1,178 @@
+package login
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type directoryEntry struct {
+	info          GameServerInfo
+	players       uint16
+	lastHeartbeat time.Time
+}
+
+// Потоко-безопасный список игровых серверов, изменяемый во время работы логин-сервера.
+//
+// Загруженность (Workload) вычисляется по кол-ву игроков, которое сообщают игровые сервера.
+// Если игровой сервер не присылал heartbeat дольше HeartbeatTimeout, то он считается недоступным.
+type Directory struct {
+	servers map[uint16]*directoryEntry
+	mu      sync.RWMutex
+	// Пороги кол-ва игроков по возрастанию. Загруженность сервера равна кол-ву достигнутых порогов (По умолчанию: 300, 700, 1000).
+	WorkloadThresholds []uint16
+	// Время, после которого сервер без heartbeat считается недоступным. 0 - не проверять (По умолчанию: 30 сек).
+	HeartbeatTimeout time.Duration
+}
+
+func (this *Directory) workload(players uint16) uint8 {
+	workload := uint8(0)
+	for _, threshold := range this.WorkloadThresholds {
+		if players >= threshold {
+			workload += 1
+		}
+	}
+	return workload
+}
+
+func (this *Directory) isAlive(entry *directoryEntry, now time.Time) bool {
+	return this.HeartbeatTimeout <= 0 || now.Sub(entry.lastHeartbeat) <= this.HeartbeatTimeout
+}
+
+// Добавить или заменить игровой сервер. Добавление считается за heartbeat
+func (this *Directory) Add(info GameServerInfo) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.servers[info.Id] = &directoryEntry{
+		info:          info,
+		lastHeartbeat: time.Now(),
+	}
+}
+
+// Удалить игровой сервер. Возвращает false если сервера не было
+func (this *Directory) Remove(id uint16) bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if _, exists := this.servers[id]; !exists {
+		return false
+	}
+
+	delete(this.servers, id)
+
+	return true
+}
+
+// Изменить информацию об игровом сервере. Возвращает false если сервера нет
+//
+// "update" - функция изменяющая информацию. Id сервера менять нельзя
+func (this *Directory) Update(id uint16, update func(info *GameServerInfo)) bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	entry, exists := this.servers[id]
+
+	if !exists {
+		return false
+	}
+
+	update(&entry.info)
+	entry.info.Id = id
+
+	return true
+}
+
+// Отметить, что игровой сервер доступен. Возвращает false если сервера нет
+func (this *Directory) Heartbeat(id uint16) bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	entry, exists := this.servers[id]
+
+	if exists {
+		entry.lastHeartbeat = time.Now()
+	}
+
+	return exists
+}
+
+// Сообщить кол-во игроков на игровом сервере. Считается за heartbeat. Возвращает false если сервера нет
+func (this *Directory) ReportPlayers(id uint16, players uint16) bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	entry, exists := this.servers[id]
+
+	if exists {
+		entry.players = players
+		entry.lastHeartbeat = time.Now()
+	}
+
+	return exists
+}
+
+// Получить кол-во игроков на игровом сервере
+func (this *Directory) Players(id uint16) (uint16, bool) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	if entry, exists := this.servers[id]; exists {
+		return entry.players, true
+	}
+
+	return 0, false
+}
+
+func (this *Directory) current(entry *directoryEntry, now time.Time) GameServerInfo {
+	info := entry.info
+	info.Online = info.Online && this.isAlive(entry, now)
+	info.Workload = this.workload(entry.players)
+	return info
+}
+
+// Получить актуальную информацию об игровом сервере
+func (this *Directory) Get(id uint16) (GameServerInfo, bool) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	if entry, exists := this.servers[id]; exists {
+		return this.current(entry, time.Now()), true
+	}
+
+	return GameServerInfo{}, false
+}
+
+// Реализация GameServerDirectory. Возвращает сервера отсортированные по id
+func (this *Directory) GameServers() []GameServerInfo {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	now := time.Now()
+	result := make([]GameServerInfo, 0, len(this.servers))
+
+	for _, entry := range this.servers {
+		result = append(result, this.current(entry, now))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+
+	return result
+}
+
+// Создает список игровых серверов
+func CreateDirectory(servers ...GameServerInfo) *Directory {
+	dir := &Directory{
+		servers:            make(map[uint16]*directoryEntry),
+		WorkloadThresholds: []uint16{300, 700, 1000},
+		HeartbeatTimeout:   time.Second * 30,
+	}
+
+	for _, info := range servers {
+		dir.Add(info)
+	}
+
+	return dir
+}
@@ -0,0 +1,165 @@
+package login
+
+import (
+	"strings"
+
+	"github.com/tuxuuman/r2o-core/pkg/launcher"
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+const (
+	// Авторизация клиента. Первый пакет присылаемый клиентом игры после разрешения подключения
+	PACKET_AUTH_REQUEST uint16 = 3100
+	// Результат авторизации со списком игровых серверов
+	PACKET_AUTH_RESULT uint16 = 3101
+	// Запрос на обновление списка игровых серверов
+	PACKET_SERVER_LIST_REQUEST uint16 = 3115
+	// Список игровых серверов
+	PACKET_SERVER_LIST uint16 = 3116
+	// Запрос на подключение к игровому серверу
+	PACKET_CONNECT_REQUEST uint16 = 3120
+	// Разрешение подключения к игровому серверу
+	PACKET_CONNECT_RESULT uint16 = 3121
+)
+
+const (
+	// хз зачем столько байт выделено под название сервера
+	SERVER_NAME_LENGTH = 101
+	// Размер параметра запуска P4 в пакете авторизации.
+	P4_LENGTH = launcher.TOKEN_MAX_LENGTH
+	// Размер параметра запуска P0 (логин) в пакете подключения к игровому серверу
+	P0_LENGTH = launcher.LOGIN_MAX_LENGTH
+)
+
+const (
+	// Ошибка "неверный идентификатор сессии"
+	ERROR_INVALID_SESSION uint32 = 1812061665
+)
+
+// Информация об игровом сервере в списке серверов
+type GameServerInfo struct {
+	// доступен ли сервера
+	Online bool
+	Id     uint16
+	Name   [SERVER_NAME_LENGTH]byte
+	// загруженность сервера
+	Workload uint8
+	// Ip
+	Ip [4]byte
+	// Порт
+	Port uint16
+	// Id списка в котором отображен сервере (1, 2)
+	ListId uint32
+	// Скрыт ли сервер (0 - видно, 1 - скрыт)
+	Hidden uint32
+}
+
+// Название сервера в виде строки
+func (this *GameServerInfo) GetName() string {
+	return trimZero(this.Name[:])
+}
+
+// Подготовить название сервера для GameServerInfo.Name
+func MakeServerName(name string) [SERVER_NAME_LENGTH]byte {
+	bname := [SERVER_NAME_LENGTH]byte{}
+
+	copy(bname[:], []byte(name))
+
+	return bname
+}
+
+func trimZero(b []byte) string {
+	return strings.Trim(string(b), "\000")
+}
+
+// Пакет авторизации (3100)
+type AuthRequest struct {
+	// не рашифрованная часть
+	_ [937]byte
+
+	// P4 параметр передаваемый в параметрах запуска. Токен, по которому ищется аккаунт
+	P4 [P4_LENGTH]byte
+}
+
+// Токен из параметра запуска P4
+func (this *AuthRequest) Token() string {
+	return trimZero(this.P4[:])
+}
+
+// Результат успешной авторизации (3101)
+type AuthResult struct {
+	// тут по всей видисмости id аккаунта
+	AccountId uint32
+	// с этим идентификатором игрок будет подключаться к игровому серверу
+	SessionId uint32
+	// Кол-во серверов. Заполняется автоматически при создании пакета
+	ServersCount uint8
+	Servers      []GameServerInfo `r2:"len=ServersCount"`
+}
+
+func (this *AuthResult) Packet() (*packet.Packet, error) {
+	return createPacket(PACKET_AUTH_RESULT, this)
+}
+
+// Запрос на обновление списка игровых серверов (3115). Без данных
+type ServerListRequest struct{}
+
+// Список игровых серверов (3116)
+type ServerList struct {
+	// Кол-во серверов. Заполняется автоматически при создании пакета
+	ServersCount uint8
+	Servers      []GameServerInfo `r2:"len=ServersCount"`
+}
+
+func (this *ServerList) Packet() (*packet.Packet, error) {
+	return createPacket(PACKET_SERVER_LIST, this)
+}
+
+// Запрос на подключение к игровому серверу (3120)
+type ConnectRequest struct {
+	// Id сэссии который мы передаем в пакете 3101
+	SessionId uint32
+	// P0 параметр запуска игра (обычно тут логин)
+	P0 [P0_LENGTH]byte
+	// Id сервера
+	ServerId uint16
+}
+
+// Логин из параметра запуска P0
+func (this *ConnectRequest) Login() string {
+	return trimZero(this.P0[:])
+}
+
+// Разрешение подключения к игровому серверу (3121)
+type ConnectResult struct {
+	// хз за что отвечает, но он должен быть. 0 - подключение разрешено
+	Code uint32
+}
+
+func (this *ConnectResult) Packet() (*packet.Packet, error) {
+	return createPacket(PACKET_CONNECT_RESULT, this)
+}
+
+func createPacket(id uint16, data interface{}) (*packet.Packet, error) {
+	b, err := packet.Marshal(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return packet.CreatePacket(id, b)
+}
+
+func init() {
+	packet.DefaultRegistry.RegisterOrPanic(PACKET_AUTH_REQUEST, "LoginAuthRequest", packet.DIRECTION_CLIENT_TO_SERVER, AuthRequest{})
+	packet.DefaultRegistry.RegisterOrPanic(PACKET_AUTH_RESULT, "LoginAuthResult", packet.DIRECTION_SERVER_TO_CLIENT, AuthResult{})
+	packet.DefaultRegistry.RegisterOrPanic(PACKET_SERVER_LIST_REQUEST, "LoginServerListRequest", packet.DIRECTION_CLIENT_TO_SERVER, ServerListRequest{})
+	packet.DefaultRegistry.RegisterOrPanic(PACKET_SERVER_LIST, "LoginServerList", packet.DIRECTION_SERVER_TO_CLIENT, ServerList{})
+	packet.DefaultRegistry.RegisterOrPanic(PACKET_CONNECT_REQUEST, "LoginConnectRequest", packet.DIRECTION_CLIENT_TO_SERVER, ConnectRequest{})
+	packet.DefaultRegistry.RegisterOrPanic(PACKET_CONNECT_RESULT, "LoginConnectResult", packet.DIRECTION_SERVER_TO_CLIENT, ConnectResult{})
+
+	packet.DefaultRegistry.RegisterOrPanic(REG_PACKET_REGISTER, "GameServerRegister", packet.DIRECTION_CLIENT_TO_SERVER, RegisterRequest{})
+	packet.DefaultRegistry.RegisterOrPanic(REG_PACKET_RESULT, "GameServerRegisterResult", packet.DIRECTION_SERVER_TO_CLIENT, RegisterResult{})
+	packet.DefaultRegistry.RegisterOrPanic(REG_PACKET_HEARTBEAT, "GameServerHeartbeat", packet.DIRECTION_CLIENT_TO_SERVER, Heartbeat{})
+	packet.DefaultRegistry.RegisterOrPanic(REG_PACKET_DEREGISTER, "GameServerDeregister", packet.DIRECTION_CLIENT_TO_SERVER, nil)
+}
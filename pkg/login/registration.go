@@ -0,0 +1,390 @@
+package login
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	gonet "net"
+	"sync"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+// Пакеты протокола регистрации игровых серверов на логин-сервере.
+//
+// Протокол использует то же обрамление пакетов что и клиент игры (pkg/net/packet), но пакеты не шифруются.
+// Игровой сервер подключается к логин-серверу, отправляет REG_PACKET_REGISTER и ждет REG_PACKET_RESULT.
+// Далее периодически отправляет REG_PACKET_HEARTBEAT с кол-вом игроков и REG_PACKET_DEREGISTER при завершении работы.
+const (
+	// Регистрация игрового сервера (RegisterRequest)
+	REG_PACKET_REGISTER uint16 = 60001
+	// Результат регистрации (RegisterResult)
+	REG_PACKET_RESULT uint16 = 60002
+	// Heartbeat с кол-вом игроков (Heartbeat)
+	REG_PACKET_HEARTBEAT uint16 = 60003
+	// Снятие игрового сервера с регистрации. Без данных
+	REG_PACKET_DEREGISTER uint16 = 60004
+)
+
+const (
+	// Максимальная длинна секретного ключа регистрации
+	REG_SECRET_LENGTH = 32
+)
+
+// Коды результата регистрации
+const (
+	REG_RESULT_OK uint32 = iota
+	// Неверный секретный ключ
+	REG_RESULT_INVALID_SECRET
+	// Первым пакетом должна быть регистрация
+	REG_RESULT_NOT_REGISTERED
+)
+
+// Пакет регистрации игрового сервера
+type RegisterRequest struct {
+	// Секретный ключ, известный логин-серверу
+	Secret [REG_SECRET_LENGTH]byte
+	// Информация об игровом сервере. Online и Workload вычисляются логин-сервером
+	Info GameServerInfo
+}
+
+// Пакет результата регистрации
+type RegisterResult struct {
+	Code uint32
+}
+
+// Пакет heartbeat
+type Heartbeat struct {
+	// Кол-во игроков на игровом сервере
+	Players uint16
+}
+
+func makeSecret(secret string) [REG_SECRET_LENGTH]byte {
+	b := [REG_SECRET_LENGTH]byte{}
+	copy(b[:], []byte(secret))
+	return b
+}
+
+// Принимает регистрацию игровых серверов и обновляет по ней Directory
+type RegistrationServer struct {
+	directory *Directory
+	secret    [REG_SECRET_LENGTH]byte
+	listener  gonet.Listener
+	mu        sync.Mutex
+	// номер последней регистрации. У каждого соединения игрового сервера свой номер
+	lastGeneration uint64
+	// номер регистрации, которой принадлежит игровой сервер в списке, по id сервера
+	owners map[uint16]uint64
+	// Максимальное время ожидания пакета от игрового сервера, после которого соединение закрывается (По умолчанию: 60 сек)
+	ReadTimeout time.Duration
+}
+
+// Начать принимать регистрацию игровых серверов. Блокирует выполнение до закрытия (Close).
+//
+// "network" - "tcp" или "unix"
+//
+// "address" - адрес, например "127.0.0.1:11010" или путь к unix сокету
+func (this *RegistrationServer) Listen(network string, address string) error {
+	ln, err := gonet.Listen(network, address)
+
+	if err != nil {
+		return err
+	}
+
+	this.mu.Lock()
+	this.listener = ln
+	this.mu.Unlock()
+
+	log.Printf("Регистрация игровых серверов запущена: %v", address)
+
+	for {
+		conn, err := ln.Accept()
+
+		if err != nil {
+			if errors.Is(err, gonet.ErrClosed) {
+				return nil
+			}
+			log.Println("Не удалось принять подключение игрового сервера", err)
+			continue
+		}
+
+		go this.handleConn(conn)
+	}
+}
+
+// Прекратить принимать регистрацию игровых серверов
+func (this *RegistrationServer) Close() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if this.listener == nil {
+		return nil
+	}
+
+	return this.listener.Close()
+}
+
+func (this *RegistrationServer) sendResult(conn gonet.Conn, code uint32) error {
+	_, err := conn.Write(packet.CreatePacketOrPanic(REG_PACKET_RESULT, &RegisterResult{Code: code}).Bytes())
+	return err
+}
+
+func (this *RegistrationServer) readPacket(conn gonet.Conn) (*packet.Packet, error) {
+	conn.SetReadDeadline(time.Now().Add(this.ReadTimeout))
+	return packet.ReadPacket(conn)
+}
+
+func (this *RegistrationServer) handleConn(conn gonet.Conn) {
+	defer conn.Close()
+
+	p, err := this.readPacket(conn)
+
+	if err != nil {
+		log.Printf("Не удалось получить пакет регистрации от %v: %v", conn.RemoteAddr(), err)
+		return
+	}
+
+	req := RegisterRequest{}
+
+	if p.Id != REG_PACKET_REGISTER {
+		this.sendResult(conn, REG_RESULT_NOT_REGISTERED)
+		return
+	}
+
+	if err := p.Read(&req); err != nil {
+		log.Printf("Некорректный пакет регистрации от %v: %v", conn.RemoteAddr(), err)
+		return
+	}
+
+	if subtle.ConstantTimeCompare(req.Secret[:], this.secret[:]) != 1 {
+		log.Printf("Игровой сервер %v указал неверный секретный ключ", conn.RemoteAddr())
+		this.sendResult(conn, REG_RESULT_INVALID_SECRET)
+		return
+	}
+
+	id := req.Info.Id
+	req.Info.Online = true
+	generation := this.register(req.Info)
+	log.Printf("Игровой сервер [%v] %v зарегистрирован с %v", id, req.Info.GetName(), conn.RemoteAddr())
+
+	if err := this.sendResult(conn, REG_RESULT_OK); err != nil {
+		log.Printf("Не удалось отправить результат регистрации игровому серверу [%v]: %v", id, err)
+	}
+
+	for {
+		p, err := this.readPacket(conn)
+
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("Соединение с игровым сервером [%v] прервано: %v", id, err)
+			}
+			// сервер мог упасть, поэтому показываем его недоступным, пока он не зарегистрируется снова
+			this.ifOwner(id, generation, func() {
+				this.directory.Update(id, func(info *GameServerInfo) {
+					info.Online = false
+				})
+			})
+			return
+		}
+
+		switch p.Id {
+		case REG_PACKET_HEARTBEAT:
+			hb := Heartbeat{}
+			if err := p.Read(&hb); err != nil {
+				log.Printf("Некорректный heartbeat от игрового сервера [%v]: %v", id, err)
+				continue
+			}
+			this.ifOwner(id, generation, func() {
+				this.directory.ReportPlayers(id, hb.Players)
+			})
+		case REG_PACKET_DEREGISTER:
+			this.ifOwner(id, generation, func() {
+				this.directory.Remove(id)
+				delete(this.owners, id)
+			})
+			log.Printf("Игровой сервер [%v] снят с регистрации", id)
+			return
+		default:
+			log.Printf("Неизвестный пакет [%d] от игрового сервера [%v]", p.Id, id)
+		}
+	}
+}
+
+// Добавить игровой сервер в список. Возвращает номер регистрации, которой теперь принадлежит сервер
+func (this *RegistrationServer) register(info GameServerInfo) uint64 {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.lastGeneration += 1
+	this.owners[info.Id] = this.lastGeneration
+	this.directory.Add(info)
+
+	return this.lastGeneration
+}
+
+// Выполнить "cb", если игровой сервер "id" все еще принадлежит регистрации "generation".
+//
+// Если игровой сервер переподключился и зарегистрировался снова, то старое соединение больше не меняет его в списке.
+func (this *RegistrationServer) ifOwner(id uint16, generation uint64, cb func()) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if this.owners[id] == generation {
+		cb()
+	}
+}
+
+// Создает сервер регистрации игровых серверов
+//
+// "dir" - список игровых серверов, который будет обновляться
+//
+// "secret" - секретный ключ, который должны указывать игровые сервера. Не пустой и не длиннее REG_SECRET_LENGTH байт
+func CreateRegistrationServer(dir *Directory, secret string) (*RegistrationServer, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("Секретный ключ регистрации не может быть пустым")
+	}
+
+	if len(secret) > REG_SECRET_LENGTH {
+		return nil, errors.New(fmt.Sprintf("Длинна секретного ключа регистрации [%v] больше допустимой [%v]", len(secret), REG_SECRET_LENGTH))
+	}
+
+	return &RegistrationServer{
+		directory:   dir,
+		secret:      makeSecret(secret),
+		owners:      make(map[uint16]uint64),
+		ReadTimeout: time.Second * 60,
+	}, nil
+}
+
+// Обертка над CreateRegistrationServer, вызывающая панику в случае ошибки.
+func CreateRegistrationServerOrPanic(dir *Directory, secret string) *RegistrationServer {
+	regServer, err := CreateRegistrationServer(dir, secret)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return regServer
+}
+
+// Регистрация игрового сервера на логин-сервере (сторона игрового сервера)
+type Registration struct {
+	conn gonet.Conn
+	mu   sync.Mutex
+	stop chan struct{}
+	once sync.Once
+}
+
+func (this *Registration) send(p *packet.Packet) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	_, err := this.conn.Write(p.Bytes())
+	return err
+}
+
+// Отправить heartbeat с кол-вом игроков
+func (this *Registration) Heartbeat(players uint16) error {
+	return this.send(packet.CreatePacketOrPanic(REG_PACKET_HEARTBEAT, &Heartbeat{Players: players}))
+}
+
+// Периодически отправлять heartbeat, пока регистрация не будет снята
+//
+// "interval" - интервал отправки. Должен быть меньше Directory.HeartbeatTimeout логин-сервера
+//
+// "players" - функция возвращающая текущее кол-во игроков
+func (this *Registration) StartHeartbeat(interval time.Duration, players func() uint16) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-this.stop:
+				return
+			case <-ticker.C:
+				if err := this.Heartbeat(players()); err != nil {
+					log.Printf("Не удалось отправить heartbeat логин-серверу: %v", err)
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Снять игровой сервер с регистрации и закрыть соединение
+func (this *Registration) Deregister() error {
+	var err error
+
+	this.once.Do(func() {
+		close(this.stop)
+		err = this.send(packet.CreatePacketOrPanic(REG_PACKET_DEREGISTER))
+		if closeErr := this.conn.Close(); err == nil {
+			err = closeErr
+		}
+	})
+
+	return err
+}
+
+// Зарегистрировать игровой сервер на логин-сервере
+//
+// "network" - "tcp" или "unix"
+//
+// "address" - адрес RegistrationServer логин-сервера
+//
+// "secret" - секретный ключ логин-сервера
+//
+// "info" - информация об игровом сервере
+func RegisterGameServer(network string, address string, secret string, info GameServerInfo) (*Registration, error) {
+	conn, err := gonet.DialTimeout(network, address, time.Second*10)
+
+	if err != nil {
+		return nil, err
+	}
+
+	reg := &Registration{
+		conn: conn,
+		stop: make(chan struct{}),
+	}
+
+	req := RegisterRequest{
+		Secret: makeSecret(secret),
+		Info:   info,
+	}
+
+	if err := reg.send(packet.CreatePacketOrPanic(REG_PACKET_REGISTER, &req)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second * 10))
+	p, err := packet.ReadPacket(conn)
+	conn.SetReadDeadline(time.Time{})
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	res := RegisterResult{}
+
+	if p.Id != REG_PACKET_RESULT {
+		conn.Close()
+		return nil, errors.New(fmt.Sprintf("Неожиданный ответ на регистрацию [%d]", p.Id))
+	}
+
+	if err := p.Read(&res); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if res.Code != REG_RESULT_OK {
+		conn.Close()
+		return nil, errors.New(fmt.Sprintf("Логин-сервер отклонил регистрацию [%v]", res.Code))
+	}
+
+	return reg, nil
+}
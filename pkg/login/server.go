@@ -0,0 +1,200 @@
+package login
+
+import (
+	"log"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/net"
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+	"github.com/tuxuuman/r2o-core/pkg/session"
+)
+
+// Проверка токена авторизации
+type Authenticator interface {
+	// Авторизовать клиента по токену из параметра запуска P4.
+	//
+	// "token" - токен авторизации
+	//
+	// "ip" - IP клиента
+	//
+	// Возвращает id аккаунта и id сессии, с которым клиент будет подключаться к игровому серверу.
+	// Если авторизация не удалась, то возвращает id ошибки (errorId != 0), которая будет отправлена клиенту.
+	Authenticate(token string, ip string) (accountId uint32, sessionId uint32, errorId uint32)
+}
+
+// Обертка позволяющая использовать обычную функцию в качестве Authenticator
+type AuthenticatorFunc func(token string, ip string) (accountId uint32, sessionId uint32, errorId uint32)
+
+func (this AuthenticatorFunc) Authenticate(token string, ip string) (uint32, uint32, uint32) {
+	return this(token, ip)
+}
+
+// Источник списка игровых серверов
+type GameServerDirectory interface {
+	// Получить актуальный список игровых серверов
+	GameServers() []GameServerInfo
+}
+
+// Неизменяемый список игровых серверов
+type StaticDirectory []GameServerInfo
+
+func (this StaticDirectory) GameServers() []GameServerInfo {
+	return this
+}
+
+// Найти игровой сервер по id
+func findGameServer(dir GameServerDirectory, id uint16) (GameServerInfo, bool) {
+	for _, gs := range dir.GameServers() {
+		if gs.Id == id {
+			return gs, true
+		}
+	}
+	return GameServerInfo{}, false
+}
+
+// Паникует если пакет не удалось создать. Паника внутри обработчика пакета будет перехвачена и клиенту отправится ошибка
+func mustPacket(p *packet.Packet, err error) *packet.Packet {
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Логин-сервер.
+//
+// Авторизует клиентов через Authenticator, отдает им список серверов из GameServerDirectory и разрешает подключение к игровым серверам.
+type Server struct {
+	net.Server
+	// Проверка токена авторизации
+	Authenticator Authenticator
+	// Источник списка игровых серверов
+	Directory GameServerDirectory
+	// Необязательное хранилище сессий, через которое игровой сервер проверит подключающегося клиента.
+	// Если указано, то id сессии выдает хранилище, а не Authenticator, и при подключении к игровому серверу сессия привязывается к нему.
+	Sessions session.Store
+	// Время действия сессии, выданной через Sessions (По умолчанию: 10 мин)
+	SessionTTL time.Duration
+	// Необязательная дополнительная проверка запроса на подключение к игровому серверу.
+	// Если вернет errorId != 0, то клиенту будет отправлена ошибка и подключение не будет разрешено.
+	ConnectHook func(c *net.Client, req *ConnectRequest, gs GameServerInfo) (errorId uint32)
+	// Необязательный коллбэк вызываемый при подключении клиента, до разрешения подключения.
+	// Можно установить дополнительные обработчики пакетов или отклонить подключение.
+	OnConnection func(c *net.Client)
+}
+
+// Запустить логин-сервер
+func (this *Server) Start() {
+	this.Server.Start(this.handleConnection)
+}
+
+func (this *Server) handleConnection(c *net.Client) {
+	// id сессии выданный клиенту при авторизации
+	var sessionId uint32
+
+	c.SetPacketHandler(PACKET_AUTH_REQUEST, func(p *packet.Packet, data interface{}) {
+		req := data.(*AuthRequest)
+
+		accountId, sid, errorId := this.Authenticator.Authenticate(req.Token(), c.IP())
+
+		if errorId != 0 {
+			log.Printf("Клиент %s не прошел авторизацию [%v]", c.IP(), errorId)
+			c.FatalError(errorId)
+			return
+		}
+
+		c.SetAccountId(accountId)
+
+		if !this.IsAllowedDuringMaintenance(c) {
+			log.Printf("Клиент %s [%v] не допущен во время технических работ", c.IP(), accountId)
+			c.FatalError(this.MaintenanceErrorId)
+			return
+		}
+
+		if this.Sessions != nil {
+			s, err := this.Sessions.Issue(accountId, c.IP(), this.SessionTTL)
+			if err != nil {
+				// паника будет перехвачена и клиенту отправится ошибка
+				panic(err)
+			}
+			sid = s.Id
+		}
+
+		sessionId = sid
+		log.Printf("Клиент %s авторизован. Аккаунт: %v", c.IP(), accountId)
+
+		res := AuthResult{
+			AccountId: accountId,
+			SessionId: sessionId,
+			Servers:   this.Directory.GameServers(),
+		}
+
+		c.SendPacket(mustPacket(res.Packet()))
+	}, &AuthRequest{}, true)
+
+	c.SetPacketHandler(PACKET_SERVER_LIST_REQUEST, func(p *packet.Packet, data interface{}) {
+		res := ServerList{Servers: this.Directory.GameServers()}
+		c.SendPacket(mustPacket(res.Packet()))
+	}, nil, false)
+
+	c.SetPacketHandler(PACKET_CONNECT_REQUEST, func(p *packet.Packet, data interface{}) {
+		req := data.(*ConnectRequest)
+
+		if c.AccountId() == 0 || req.SessionId != sessionId {
+			log.Printf("Клиент %s запросил подключение к серверу [%v] с неверной сессией [%v]", c.IP(), req.ServerId, req.SessionId)
+			c.FatalError(ERROR_INVALID_SESSION)
+			return
+		}
+
+		gs, exists := findGameServer(this.Directory, req.ServerId)
+
+		if !exists || !gs.Online {
+			log.Printf("Клиент %s запросил подключение к недоступному серверу [%v]", c.IP(), req.ServerId)
+			c.Error(PACKET_CONNECT_REQUEST, net.ERROR_PACKET_HANDLING, 0)
+			return
+		}
+
+		if this.ConnectHook != nil {
+			if errorId := this.ConnectHook(c, req, gs); errorId != 0 {
+				c.Error(PACKET_CONNECT_REQUEST, errorId, 0)
+				return
+			}
+		}
+
+		if this.Sessions != nil {
+			if err := this.Sessions.Bind(sessionId, gs.Id); err != nil {
+				log.Printf("Не удалось привязать сессию клиента %s к серверу [%v]: %v", c.IP(), gs.Id, err)
+				c.FatalError(ERROR_INVALID_SESSION)
+				return
+			}
+		}
+
+		log.Printf("Клиент %s [%v] подключается к серверу [%v] %v", c.IP(), req.Login(), gs.Id, gs.GetName())
+
+		// после этого игрок отключится от логин-сервера и начнет подключение к игровому
+		res := ConnectResult{Code: 0}
+		c.SendPacket(mustPacket(res.Packet()))
+	}, &ConnectRequest{}, false)
+
+	if this.OnConnection != nil {
+		this.OnConnection(c)
+	}
+
+	// обработчик мог отклонить подключение
+	if !c.IsRejected() {
+		c.Accept()
+	}
+}
+
+// Создает логин-сервер
+//
+// "auth" - проверка токенов авторизации
+//
+// "dir" - источник списка игровых серверов
+func CreateServer(host string, port uint16, auth Authenticator, dir GameServerDirectory) *Server {
+	return &Server{
+		Server:        net.CreateServer(host, port),
+		Authenticator: auth,
+		Directory:     dir,
+		SessionTTL:    time.Minute * 10,
+	}
+}
@@ -1,13 +1,13 @@
 package net
 
 import (
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"runtime/debug"
+	"sync/atomic"
 
 	"github.com/tuxuuman/r2o-core/internal/events"
 	"github.com/tuxuuman/r2o-core/pkg/net/packet"
@@ -23,15 +23,25 @@ type packetHandler struct {
 }
 
 type Client struct {
-	emitter        events.Emitter
-	packetHandlers map[uint16]packetHandler
-	conn           net.Conn
-	accepted       bool
-	rejected       bool
-	isClosed       bool
-	disconChan     bool
-	ip             string
-	id             uint16
+	emitter                 *events.Emitter
+	packetHandlers          map[uint16]packetHandler
+	rateLimiter             *packetLimiter
+	packetRateLimiters      map[uint16]*packetLimiter
+	rateLimitCounters       *rateLimitCounters
+	serverRateLimitCounters *rateLimitCounters
+	conn                    net.Conn
+	capture                 *packet.CaptureWriter
+	captureConnId           uint32
+	// подключение установлено нами через Connect, а не принято сервером
+	dialed     bool
+	accepted   bool
+	rejected   bool
+	isClosed   bool
+	disconChan bool
+	ip         string
+	id         uint16
+	// читается и пишется из разных горутин, только через atomic
+	accountId uint32
 }
 
 func (this *Client) close() {
@@ -48,18 +58,43 @@ func (this *Client) Close() error {
 }
 
 func createFatalErrorPacket(erorrId uint32) *packet.Packet {
-	return packet.CreatePacketOrPanic(3102, uint32(erorrId))
+	return packet.CreatePacketOrPanic(PACKET_FATAL_ERROR, &FatalErrorPacket{ErrorId: erorrId})
 }
 
 func createErrorPacket(packetId uint16, erorrId uint32, code uint32) *packet.Packet {
-	return packet.CreatePacketOrPanic(1102, uint16(packetId), uint32(erorrId), uint32(code))
+	return packet.CreatePacketOrPanic(PACKET_ERROR, &ErrorPacket{PacketId: packetId, ErrorId: erorrId, Code: code})
+}
+
+// Записать пакет в том виде, в котором он передается по сети, если включена запись трафика
+func (this *Client) capturePacket(p *packet.Packet, incoming bool) {
+	if this.capture == nil {
+		return
+	}
+
+	direction := packet.DIRECTION_SERVER_TO_CLIENT
+	if incoming != this.dialed {
+		direction = packet.DIRECTION_CLIENT_TO_SERVER
+	}
+
+	if err := this.capture.WritePacket(direction, this.captureConnId, p); err != nil {
+		log.Printf("Не удалось записать пакет [%v] клиента %v в запись трафика: %v", packet.DefaultRegistry.Name(p.Id), this.ip, err)
+	}
+}
+
+// Включить запись трафика клиента в "w". Каждому клиенту выделяется свой id соединения в записи.
+//
+// Сервер включает запись для новых клиентов сам, если указан Server.Capture.
+func (this *Client) SetCapture(w *packet.CaptureWriter) {
+	this.capture = w
+	this.captureConnId = w.NewConnId()
 }
 
 func (this *Client) sendPacket(p *packet.Packet) {
 	log.Print("\n\n->->->->->->->->->->->->->->->->\n\n", fmt.Sprintf("Исходящий пакет для [%v:%v]\n", this.ip, this.id), p.String(), "\n->->->->->->->->->->->->->->->->\n\n")
+	this.capturePacket(p, false)
 	_, err := this.conn.Write(p.Bytes())
 	if err != nil {
-		log.Printf(fmt.Sprintf("Не удалось отправить пакет [ID=%v]", p.Id), err)
+		log.Printf("Не удалось отправить пакет [%v]: %v", packet.DefaultRegistry.Name(p.Id), err)
 	}
 }
 
@@ -73,8 +108,8 @@ func (this *Client) handlePacket(p *packet.Packet) {
 				this.RemovePacketHandler(packetId)
 			}
 			if r := recover(); r != nil {
-				log.Printf("Возникла ошибка при обработки пакета [%d]: %s", packetId, r)
-				this.sendPacket(createErrorPacket(packetId, 2547627153, 0))
+				log.Printf("Возникла ошибка при обработки пакета [%v]: %s", packet.DefaultRegistry.Name(packetId), r)
+				this.sendPacket(createErrorPacket(packetId, ERROR_PACKET_HANDLING, 0))
 			}
 		}(p.Id)
 
@@ -87,7 +122,7 @@ func (this *Client) handlePacket(p *packet.Packet) {
 
 		ph.Handle(p, ph.Struct)
 	} else {
-		log.Printf("Необработанный пакет [%d]", p.Id)
+		log.Printf("Необработанный пакет [%v]", packet.DefaultRegistry.Name(p.Id))
 	}
 }
 
@@ -95,24 +130,18 @@ func (this *Client) startPacketReader() {
 	var err error
 
 	for {
-		bufLen := make([]byte, 2)
-		_, err = io.ReadFull(this.conn, bufLen)
-
-		if err != nil {
-			break
-		}
-
-		bufPac := make([]byte, binary.LittleEndian.Uint16(bufLen)-2)
-		_, err = io.ReadFull(this.conn, bufPac)
+		var p *packet.Packet
+		p, err = packet.ReadPacket(this.conn)
 
 		if err != nil {
 			break
 		}
 
-		p, err := packet.CreatePacketFromBytes(append(bufLen, bufPac...))
+		this.capturePacket(p, true)
 
-		if err != nil {
-			break
+		// id пакета известен без расшифровки данных, поэтому лишние пакеты отбрасываются до расшифровки и вывода в лог
+		if !this.checkRateLimit(p) {
+			continue
 		}
 
 		if p.IsEncrypted() {
@@ -120,6 +149,9 @@ func (this *Client) startPacketReader() {
 		}
 
 		log.Print("\n\n<-<-<-<-<-<-<-<-<-<-<-<-<-<-<-<-\n\n", fmt.Sprintf("Входящий пакет от [%v:%v]", this.ip, this.id), p.String(), "\n<-<-<-<-<-<-<-<-<-<-<-<-<-<-<-<-\n\n")
+
+		this.emitter.Emit("packet", p)
+
 		this.handlePacket(p)
 	}
 
@@ -137,6 +169,15 @@ func (this *Client) OnDisconnect(cb func(), once bool) {
 	}, once)
 }
 
+// Добавить обработчик всех входящих пакетов (уже расшифрованных). Вызывается до обработчиков пакетов.
+//
+// Пакеты, отброшенные ограничениями частоты (SetRateLimit, SetPacketRateLimit), в обработчик не передаются
+func (this *Client) OnPacket(cb func(p *packet.Packet), once bool) {
+	this.emitter.AddEventHandler("packet", func(args ...interface{}) {
+		cb(args[0].(*packet.Packet))
+	}, once)
+}
+
 func (this *Client) ID() uint16 {
 	return this.id
 }
@@ -145,6 +186,16 @@ func (this *Client) IP() string {
 	return this.ip
 }
 
+// Привязать к клиенту id аккаунта, после его авторизации
+func (this *Client) SetAccountId(accountId uint32) {
+	atomic.StoreUint32(&this.accountId, accountId)
+}
+
+// Получить id аккаунта клиента. 0 - клиент еще не авторизован
+func (this *Client) AccountId() uint32 {
+	return atomic.LoadUint32(&this.accountId)
+}
+
 func (this *Client) SetPacketHandler(packetId uint16, handle func(p *packet.Packet, data interface{}), packetStruct interface{}, once bool) {
 	this.packetHandlers[packetId] = packetHandler{
 		Handle: handle,
@@ -161,6 +212,16 @@ func (this *Client) SendPacket(p *packet.Packet) {
 	this.sendPacket(p)
 }
 
+// Принято ли подключение клиента
+func (this *Client) IsAccepted() bool {
+	return this.accepted
+}
+
+// Отклонено ли подключение клиента
+func (this *Client) IsRejected() bool {
+	return this.rejected
+}
+
 // Разрешить подключение клиента и начать принимать пакеты.
 //
 // После подключения клиента обязательно нужно вызвать этот метод или метод Reject, иначе Reject будет вызван автоматически, спустя некоторое время.
@@ -214,12 +275,17 @@ func (this *Client) FatalError(errorId uint32) {
 }
 
 func createClient(id uint16, conn net.Conn) Client {
+	// эмиттер общий для копий клиента (см. Connect)
+	emitter := events.CreateEmitter()
+
 	return Client{
-		packetHandlers: make(map[uint16]packetHandler),
-		conn:           conn,
-		ip:             conn.RemoteAddr().(*net.TCPAddr).IP.String(),
-		id:             id,
-		emitter:        events.CreateEmitter(),
+		packetHandlers:     make(map[uint16]packetHandler),
+		packetRateLimiters: make(map[uint16]*packetLimiter),
+		rateLimitCounters:  &rateLimitCounters{},
+		conn:               conn,
+		ip:                 conn.RemoteAddr().(*net.TCPAddr).IP.String(),
+		id:                 id,
+		emitter:            &emitter,
 	}
 }
 
@@ -231,13 +297,8 @@ func Connect(addr string, onConnection func(c Client), onConnectionError func(er
 		return
 	}
 
-	c := Client{
-		packetHandlers: make(map[uint16]packetHandler),
-		conn:           conn,
-		ip:             conn.RemoteAddr().(*net.TCPAddr).IP.String(),
-		id:             1,
-		emitter:        events.CreateEmitter(),
-	}
+	c := createClient(1, conn)
+	c.dialed = true
 
 	onConnection(c)
 
@@ -0,0 +1,163 @@
+package net
+
+import (
+	"log"
+	"sync/atomic"
+
+	"github.com/tuxuuman/r2o-core/internal/ratelimit"
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+// Действие при превышении ограничения частоты пакетов
+type RateLimitAction uint8
+
+const (
+	// Пакет отбрасывается без обработки
+	RATE_LIMIT_ACTION_DROP RateLimitAction = iota
+	// Пакет отбрасывается, клиенту отправляется ошибка (Client.Error)
+	RATE_LIMIT_ACTION_ERROR
+	// Клиент отключается
+	RATE_LIMIT_ACTION_DISCONNECT
+)
+
+// Ограничение частоты пакетов клиента
+type PacketRateLimit struct {
+	// Допустимое кол-во пакетов в секунду. 0 - без ограничений
+	Rate float64
+	// Сколько пакетов можно прислать подряд, прежде чем начнет действовать ограничение Rate
+	Burst uint16
+	// Действие при превышении ограничения
+	Action RateLimitAction
+	// Id ошибки для RATE_LIMIT_ACTION_ERROR (По умолчанию: ERROR_PACKET_HANDLING)
+	ErrorId uint32
+}
+
+// Статистика срабатывания ограничений частоты пакетов
+type RateLimitStats struct {
+	// Кол-во отброшенных пакетов (включая пакеты, в ответ на которые отправлена ошибка)
+	Dropped uint64
+	// Кол-во отправленных ошибок
+	Errors uint64
+	// Кол-во отключенных клиентов
+	Disconnects uint64
+}
+
+type rateLimitCounters struct {
+	dropped     uint64
+	errors      uint64
+	disconnects uint64
+}
+
+func (this *rateLimitCounters) add(action RateLimitAction) {
+	atomic.AddUint64(&this.dropped, 1)
+	switch action {
+	case RATE_LIMIT_ACTION_ERROR:
+		atomic.AddUint64(&this.errors, 1)
+	case RATE_LIMIT_ACTION_DISCONNECT:
+		atomic.AddUint64(&this.disconnects, 1)
+	}
+}
+
+func (this *rateLimitCounters) stats() RateLimitStats {
+	return RateLimitStats{
+		Dropped:     atomic.LoadUint64(&this.dropped),
+		Errors:      atomic.LoadUint64(&this.errors),
+		Disconnects: atomic.LoadUint64(&this.disconnects),
+	}
+}
+
+type packetLimiter struct {
+	limit  PacketRateLimit
+	bucket *ratelimit.TokenBucket
+}
+
+func createPacketLimiter(limit PacketRateLimit) *packetLimiter {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	return &packetLimiter{
+		limit:  limit,
+		bucket: ratelimit.CreateTokenBucket(limit.Rate, limit.Burst),
+	}
+}
+
+// Установить ограничение частоты всех пакетов клиента. Ограничение с Rate = 0 снимает ограничение.
+func (this *Client) SetRateLimit(limit PacketRateLimit) {
+	this.rateLimiter = createPacketLimiter(limit)
+}
+
+// Установить ограничение частоты пакетов с id "packetId". Действует дополнительно к ограничению SetRateLimit.
+func (this *Client) SetPacketRateLimit(packetId uint16, limit PacketRateLimit) {
+	if limiter := createPacketLimiter(limit); limiter != nil {
+		this.packetRateLimiters[packetId] = limiter
+	} else {
+		delete(this.packetRateLimiters, packetId)
+	}
+}
+
+// Снять ограничение частоты пакетов с id "packetId"
+func (this *Client) RemovePacketRateLimit(packetId uint16) {
+	delete(this.packetRateLimiters, packetId)
+}
+
+// Получить статистику срабатывания ограничений частоты пакетов клиента
+func (this *Client) RateLimitStats() RateLimitStats {
+	return this.rateLimitCounters.stats()
+}
+
+// Проверить ограничения частоты для входящего пакета и выполнить действие при их превышении.
+//
+// Возвращает false если пакет не должен обрабатываться.
+func (this *Client) checkRateLimit(p *packet.Packet) bool {
+	packetLimiter := this.packetRateLimiters[p.Id]
+	limiter := packetLimiter
+
+	// токены забираются, только если пакет проходит оба ограничения. Иначе отброшенный пакет расходовал бы токен другого ограничения
+	if limiter == nil || limiter.bucket.HasToken() {
+		limiter = this.rateLimiter
+		if limiter == nil || limiter.bucket.HasToken() {
+			if packetLimiter != nil {
+				packetLimiter.bucket.Allow()
+			}
+			if this.rateLimiter != nil {
+				this.rateLimiter.bucket.Allow()
+			}
+			return true
+		}
+	}
+
+	action := limiter.limit.Action
+
+	this.rateLimitCounters.add(action)
+	if this.serverRateLimitCounters != nil {
+		this.serverRateLimitCounters.add(action)
+	}
+
+	switch action {
+	case RATE_LIMIT_ACTION_ERROR:
+		errorId := limiter.limit.ErrorId
+		if errorId == 0 {
+			errorId = ERROR_PACKET_HANDLING
+		}
+		this.Error(p.Id, errorId, 0)
+	case RATE_LIMIT_ACTION_DISCONNECT:
+		log.Printf("Клиент [%v:%v] превысил ограничение частоты пакетов [%d] и будет отключен", this.ip, this.id, p.Id)
+		this.Close()
+	}
+
+	return false
+}
+
+// Получить статистику срабатывания ограничений частоты пакетов всех клиентов сервера
+func (this *Server) RateLimitStats() RateLimitStats {
+	return this.rateLimitCounters.stats()
+}
+
+// Применить к клиенту ограничения частоты пакетов сервера
+func (this *Server) applyRateLimits(c *Client) {
+	c.serverRateLimitCounters = this.rateLimitCounters
+	c.SetRateLimit(this.ClientRateLimit)
+	for packetId, limit := range this.PacketRateLimits {
+		c.SetPacketRateLimit(packetId, limit)
+	}
+}
@@ -0,0 +1,89 @@
+package net
+
+import (
+	"log"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/internal/ratelimit"
+)
+
+const (
+	// Превышено кол-во одновременных подключений с одного IP
+	LIMIT_CONNECTIONS_PER_IP = "connectionsPerIp"
+	// Превышена частота новых подключений с одного IP
+	LIMIT_CONNECTION_RATE = "connectionRate"
+)
+
+// Как часто удалять неиспользуемые корзины ограничения частоты подключений
+const connectionBucketsSweepInterval = time.Minute
+
+// Занять место для нового подключения с "ip" с учетом ограничений MaxConnectionsPerIP и ConnectionRate.
+//
+// Возвращает название сработавшего ограничения, или пустую строку если подключение допустимо.
+func (this *Server) acquireIPSlot(ip string) string {
+	this.limitsMu.Lock()
+	defer this.limitsMu.Unlock()
+
+	if this.ConnectionRate > 0 {
+		now := time.Now()
+		if now.Sub(this.lastBucketsSweep) > connectionBucketsSweepInterval {
+			for bucketIp, bucket := range this.ipBuckets {
+				if bucket.IsFull() {
+					delete(this.ipBuckets, bucketIp)
+				}
+			}
+			this.lastBucketsSweep = now
+		}
+
+		bucket, exists := this.ipBuckets[ip]
+		if !exists {
+			bucket = ratelimit.CreateTokenBucket(this.ConnectionRate, this.ConnectionBurst)
+			this.ipBuckets[ip] = bucket
+		}
+
+		if !bucket.Allow() {
+			return LIMIT_CONNECTION_RATE
+		}
+	}
+
+	if this.MaxConnectionsPerIP > 0 && this.ipConnections[ip] >= this.MaxConnectionsPerIP {
+		return LIMIT_CONNECTIONS_PER_IP
+	}
+
+	this.ipConnections[ip] += 1
+
+	return ""
+}
+
+// Освободить место подключения занятое через acquireIPSlot
+func (this *Server) releaseIPSlot(ip string) {
+	this.limitsMu.Lock()
+	defer this.limitsMu.Unlock()
+
+	if this.ipConnections[ip] <= 1 {
+		delete(this.ipConnections, ip)
+	} else {
+		this.ipConnections[ip] -= 1
+	}
+}
+
+// Получить кол-во текущих подключений с "ip"
+func (this *Server) GetIPConnectionsCount(ip string) uint16 {
+	this.limitsMu.Lock()
+	defer this.limitsMu.Unlock()
+	return this.ipConnections[ip]
+}
+
+func (this *Server) limitExceeded(ip string, limit string) {
+	log.Printf("Подключение клиента %v отклонено. Превышено ограничение [%v]", ip, limit)
+	this.emitter.Emit("limit", ip, limit)
+}
+
+// Подписаться на срабатывание ограничений подключений.
+//
+// "cb" - будет вызвана с IP клиента и названием ограничения (LIMIT_CONNECTIONS_PER_IP, LIMIT_CONNECTION_RATE)
+func (this *Server) OnLimitExceeded(cb func(ip string, limit string), once bool) {
+	this.emitter.AddEventHandler("limit", func(args ...interface{}) {
+		cb(args[0].(string), args[1].(string))
+	}, once)
+}
@@ -0,0 +1,141 @@
+package net
+
+import (
+	"log"
+	"net"
+	"sync"
+)
+
+// Состояние режима технических работ сервера
+type maintenance struct {
+	enabled  bool
+	ips      map[string]bool
+	accounts map[uint32]bool
+	mu       sync.RWMutex
+}
+
+func createMaintenance() *maintenance {
+	return &maintenance{
+		ips:      make(map[string]bool),
+		accounts: make(map[uint32]bool),
+	}
+}
+
+// Включить режим технических работ.
+//
+// Клиентам без доступа (IsAllowedDuringMaintenance) нужно отказывать после авторизации, когда станет известен их аккаунт.
+// Если включен MaintenanceRejectByIP, то новые подключения с IP не из списка разрешенных (AllowMaintenanceIP) отклоняются сразу с ошибкой MaintenanceErrorId.
+//
+// "kick" - отключить уже подключенных клиентов, которым не разрешен доступ, отправив им ошибку MaintenanceErrorId.
+// Клиенты в очереди ожидания еще не авторизованы, поэтому из нее убираются все клиенты с IP не из списка разрешенных
+func (this *Server) EnableMaintenance(kick bool) {
+	this.maintenance.mu.Lock()
+	this.maintenance.enabled = true
+	this.maintenance.mu.Unlock()
+
+	log.Printf("Сервер переведен в режим технических работ")
+
+	if kick {
+		this.runTask(func() {
+			for _, cl := range this.clients {
+				if !this.IsAllowedDuringMaintenance(cl) {
+					log.Printf("Клиент [%v:%v] отключен в связи с техническими работами", cl.ip, cl.id)
+					cl.FatalError(this.MaintenanceErrorId)
+					cl.Close()
+				}
+			}
+
+			queue := append([]*queuedClient{}, this.queue...)
+
+			for _, qc := range queue {
+				if !this.IsAllowedDuringMaintenance(qc.client) && this.removeFromQueue(qc) {
+					log.Printf("Клиент %v убран из очереди ожидания в связи с техническими работами", qc.client.ip)
+					this.releaseIPSlot(qc.client.ip)
+					qc.client.Reject(this.MaintenanceErrorId)
+				}
+			}
+
+			this.notifyQueuePositions()
+		})
+	}
+}
+
+// Выключить режим технических работ
+func (this *Server) DisableMaintenance() {
+	this.maintenance.mu.Lock()
+	this.maintenance.enabled = false
+	this.maintenance.mu.Unlock()
+
+	log.Printf("Режим технических работ выключен")
+}
+
+// Включен ли режим технических работ
+func (this *Server) IsMaintenance() bool {
+	this.maintenance.mu.RLock()
+	defer this.maintenance.mu.RUnlock()
+	return this.maintenance.enabled
+}
+
+// Разрешить подключения с "ip" во время технических работ
+func (this *Server) AllowMaintenanceIP(ip string) {
+	this.maintenance.mu.Lock()
+	defer this.maintenance.mu.Unlock()
+	this.maintenance.ips[ip] = true
+}
+
+// Убрать "ip" из списка разрешенных во время технических работ
+func (this *Server) DisallowMaintenanceIP(ip string) {
+	this.maintenance.mu.Lock()
+	defer this.maintenance.mu.Unlock()
+	delete(this.maintenance.ips, ip)
+}
+
+// Разрешить доступ аккаунту (например GM) во время технических работ.
+//
+// Аккаунт клиента становится известен только после авторизации (Client.SetAccountId), поэтому проверка по аккаунту выполняется
+// при отключении клиентов (EnableMaintenance) и в коде авторизации через IsAllowedDuringMaintenance.
+// При включенном MaintenanceRejectByIP аккаунты с IP не из списка разрешенных не допускаются.
+func (this *Server) AllowMaintenanceAccount(accountId uint32) {
+	this.maintenance.mu.Lock()
+	defer this.maintenance.mu.Unlock()
+	this.maintenance.accounts[accountId] = true
+}
+
+// Убрать аккаунт из списка разрешенных во время технических работ
+func (this *Server) DisallowMaintenanceAccount(accountId uint32) {
+	this.maintenance.mu.Lock()
+	defer this.maintenance.mu.Unlock()
+	delete(this.maintenance.accounts, accountId)
+}
+
+// Разрешен ли клиенту доступ к серверу с учетом режима технических работ.
+//
+// Если режим выключен, то доступ разрешен всем. Иначе только клиентам с разрешенным IP или аккаунтом.
+func (this *Server) IsAllowedDuringMaintenance(c *Client) bool {
+	this.maintenance.mu.RLock()
+	defer this.maintenance.mu.RUnlock()
+
+	if !this.maintenance.enabled || this.maintenance.ips[c.ip] {
+		return true
+	}
+
+	accountId := c.AccountId()
+
+	return accountId != 0 && this.maintenance.accounts[accountId]
+}
+
+// Проверка допуска новых подключений во время технических работ. Без MaintenanceRejectByIP допускаются все подключения
+func (this *Server) admitMaintenance(remoteAddr net.Addr) (bool, uint32) {
+	if !this.MaintenanceRejectByIP {
+		return true, 0
+	}
+
+	this.maintenance.mu.RLock()
+	defer this.maintenance.mu.RUnlock()
+
+	if this.maintenance.enabled && !this.maintenance.ips[remoteAddr.(*net.TCPAddr).IP.String()] {
+		return false, this.MaintenanceErrorId
+	}
+
+	return true, 0
+}
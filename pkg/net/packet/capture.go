@@ -0,0 +1,285 @@
+package packet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Формат файла записи трафика (.r2pac).
+//
+// Файл начинается с заголовка (captureHeader), за которым следуют записи. Каждая запись - это заголовок записи (recordHeader)
+// и байты пакета в том виде, в котором они были переданы по сети (зашифрованные пакеты остаются зашифрованными).
+// Все числа записываются в little-endian.
+
+// Сигнатура файла записи трафика
+var captureMagic = [4]byte{'R', '2', 'P', 'C'}
+
+// Версия формата записи трафика
+const CAPTURE_VERSION uint16 = 1
+
+type captureHeader struct {
+	Magic   [4]byte
+	Version uint16
+	// Время начала записи в наносекундах с начала эпохи Unix
+	StartTime int64
+}
+
+type recordHeader struct {
+	// Время передачи пакета в наносекундах с начала эпохи Unix
+	Time      int64
+	Direction Direction
+	ConnId    uint32
+	Length    uint32
+}
+
+// Запись о переданном пакете
+type Record struct {
+	// Время передачи пакета
+	Time time.Time
+	// Направление пакета
+	Direction Direction
+	// Id соединения, через которое был передан пакет. Уникален в пределах одного файла записи
+	ConnId uint32
+	// Байты пакета в том виде, в котором они были переданы по сети
+	Data []byte
+}
+
+// Создать пакет из записи. Пакет будет в том виде, в котором был передан (если он был зашифрован, то останется зашифрованным)
+func (this *Record) Packet() (*Packet, error) {
+	return CreatePacketFromBytes(append([]byte{}, this.Data...))
+}
+
+// Потоко-безопасная запись трафика в формате .r2pac
+type CaptureWriter struct {
+	w          io.Writer
+	closer     io.Closer
+	mu         sync.Mutex
+	lastConnId uint32
+}
+
+// Получить новый id соединения для записи трафика
+func (this *CaptureWriter) NewConnId() uint32 {
+	return atomic.AddUint32(&this.lastConnId, 1)
+}
+
+// Записать пакет
+func (this *CaptureWriter) Write(rec Record) error {
+	buf := new(bytes.Buffer)
+
+	err := binary.Write(buf, binary.LittleEndian, recordHeader{
+		Time:      rec.Time.UnixNano(),
+		Direction: rec.Direction,
+		ConnId:    rec.ConnId,
+		Length:    uint32(len(rec.Data)),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	buf.Write(rec.Data)
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	// запись одним вызовом, чтобы записи разных соединений не перемешались
+	_, err = this.w.Write(buf.Bytes())
+
+	return err
+}
+
+// Записать пакет "p" в его текущем виде с текущим временем
+func (this *CaptureWriter) WritePacket(direction Direction, connId uint32, p *Packet) error {
+	return this.Write(Record{
+		Time:      time.Now(),
+		Direction: direction,
+		ConnId:    connId,
+		Data:      p.Bytes(),
+	})
+}
+
+// Завершить запись. Закрывает файл, если запись была создана через CreateCaptureFile
+func (this *CaptureWriter) Close() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if this.closer != nil {
+		return this.closer.Close()
+	}
+
+	return nil
+}
+
+// Создает запись трафика в "w" и записывает в него заголовок
+func CreateCaptureWriter(w io.Writer) (*CaptureWriter, error) {
+	err := binary.Write(w, binary.LittleEndian, captureHeader{
+		Magic:     captureMagic,
+		Version:   CAPTURE_VERSION,
+		StartTime: time.Now().UnixNano(),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &CaptureWriter{w: w}, nil
+}
+
+// Создает файл записи трафика. Если файл существует, то он будет перезаписан
+func CreateCaptureFile(path string) (*CaptureWriter, error) {
+	f, err := os.Create(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cw, err := CreateCaptureWriter(f)
+
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	cw.closer = f
+
+	return cw, nil
+}
+
+// Чтение записи трафика в формате .r2pac
+type CaptureReader struct {
+	r io.Reader
+	// Время начала записи
+	StartTime time.Time
+}
+
+// Прочитать следующую запись. Возвращает io.EOF, если записей больше нет
+func (this *CaptureReader) Next() (Record, error) {
+	h := recordHeader{}
+
+	if err := binary.Read(this.r, binary.LittleEndian, &h); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Record{}, errors.New("Запись трафика обрывается на заголовке записи")
+		}
+		return Record{}, err
+	}
+
+	// длинна пакета в его заголовке - uint16, поэтому большая длинна означает поврежденную запись. Проверяется до выделения памяти
+	if h.Length < headersLength || h.Length > math.MaxUint16 {
+		return Record{}, errors.New(fmt.Sprintf("Запись трафика содержит некорректную длинну пакета [%v]", h.Length))
+	}
+
+	data := make([]byte, h.Length)
+
+	if _, err := io.ReadFull(this.r, data); err != nil {
+		return Record{}, errors.New(fmt.Sprintf("Запись трафика обрывается на данных пакета: %v", err))
+	}
+
+	return Record{
+		Time:      time.Unix(0, h.Time),
+		Direction: h.Direction,
+		ConnId:    h.ConnId,
+		Data:      data,
+	}, nil
+}
+
+// Прочитать все оставшиеся записи
+func (this *CaptureReader) ReadAll() ([]Record, error) {
+	records := []Record{}
+
+	for {
+		rec, err := this.Next()
+
+		if err == io.EOF {
+			return records, nil
+		}
+
+		if err != nil {
+			return records, err
+		}
+
+		records = append(records, rec)
+	}
+}
+
+// Создает чтение записи трафика из "r" и читает заголовок
+func CreateCaptureReader(r io.Reader) (*CaptureReader, error) {
+	h := captureHeader{}
+
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return nil, errors.New(fmt.Sprintf("Не удалось прочитать заголовок записи трафика: %v", err))
+	}
+
+	if h.Magic != captureMagic {
+		return nil, errors.New("Данные не являются записью трафика r2pac")
+	}
+
+	if h.Version != CAPTURE_VERSION {
+		return nil, errors.New(fmt.Sprintf("Версия записи трафика [%v] не поддерживается", h.Version))
+	}
+
+	return &CaptureReader{
+		r:         r,
+		StartTime: time.Unix(0, h.StartTime),
+	}, nil
+}
+
+// Начинаются ли данные с сигнатуры записи трафика
+func IsCapture(b []byte) bool {
+	return bytes.HasPrefix(b, captureMagic[:])
+}
+
+// Прочитать все записи из файла записи трафика.
+//
+// Файл без сигнатуры записи трафика (например resources/acp.r2pac) читается как байты пакетов, идущие друг за другом.
+// У таких записей нет времени, направления и соединения.
+func ReadCaptureFile(path string) ([]Record, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	if magic, _ := r.Peek(len(captureMagic)); !IsCapture(magic) {
+		return readPacketRecords(r)
+	}
+
+	cr, err := CreateCaptureReader(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cr.ReadAll()
+}
+
+// Прочитать пакеты, идущие друг за другом, в виде записей без времени, направления и соединения
+func readPacketRecords(r io.Reader) ([]Record, error) {
+	records := []Record{}
+
+	for {
+		p, err := ReadPacket(r)
+
+		if err == io.EOF {
+			return records, nil
+		}
+
+		if err != nil {
+			return records, errors.New(fmt.Sprintf("Не удалось прочитать пакет #%v: %v", len(records)+1, err))
+		}
+
+		records = append(records, Record{Data: p.Bytes()})
+	}
+}
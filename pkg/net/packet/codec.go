@@ -0,0 +1,336 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// Кодирование данных пакета на основе рефлексии.
+//
+// В отличие от encoding/binary поддерживает срезы переменной длинны внутри структур, например список серверов.
+// Длинна среза берется из поля-счетчика, указанного в теге "r2", которое должно идти раньше среза:
+//
+//	type ServerList struct {
+//		ServersCount uint8
+//		Servers      []GameServerInfo `r2:"len=ServersCount"`
+//	}
+//
+// Срез без тега занимает все оставшиеся данные пакета, поэтому может быть только последним полем.
+// Поля с именем "_" пропускаются при чтении и заполняются нулями при записи.
+
+const tagName = "r2"
+
+// Получить имя поля-счетчика из тега "r2"
+func lenFieldName(field reflect.StructField) string {
+	for _, opt := range strings.Split(field.Tag.Get(tagName), ",") {
+		if strings.HasPrefix(opt, "len=") {
+			return strings.TrimPrefix(opt, "len=")
+		}
+	}
+	return ""
+}
+
+func isFixedKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.Int8, reflect.Uint8, reflect.Int16, reflect.Uint16,
+		reflect.Int32, reflect.Uint32, reflect.Int64, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// Размер значения типа "t" в байтах, или -1 если размер не фиксирован
+func fixedSize(t reflect.Type) int {
+	switch {
+	case isFixedKind(t.Kind()):
+		return int(t.Size())
+	case t.Kind() == reflect.Array:
+		elemSize := fixedSize(t.Elem())
+		if elemSize < 0 {
+			return -1
+		}
+		return elemSize * t.Len()
+	case t.Kind() == reflect.Struct:
+		size := 0
+		for i := 0; i < t.NumField(); i++ {
+			fieldSize := fixedSize(t.Field(i).Type)
+			if fieldSize < 0 {
+				return -1
+			}
+			size += fieldSize
+		}
+		return size
+	}
+	return -1
+}
+
+func intValue(v reflect.Value) (int, bool) {
+	switch v.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), true
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(v.Uint()), true
+	}
+	return 0, false
+}
+
+type decoder struct {
+	data   []byte
+	offset int
+	// вызывается для каждого прочитанного конечного значения: числа, bool, массива байт или пропущенного поля
+	visit func(path string, offset int, size int, v reflect.Value)
+}
+
+func isByteArray(t reflect.Type) bool {
+	return t.Kind() == reflect.Array && t.Elem().Kind() == reflect.Uint8
+}
+
+func (this *decoder) take(n int, path string) ([]byte, error) {
+	if this.offset+n > len(this.data) {
+		return nil, errors.New(fmt.Sprintf("Не хватает данных для поля [%v]: нужно %v байт со смещения %v, а в пакете %v байт", path, n, this.offset, len(this.data)))
+	}
+	b := this.data[this.offset : this.offset+n]
+	this.offset += n
+	return b, nil
+}
+
+func (this *decoder) decode(v reflect.Value, path string) error {
+	t := v.Type()
+	start := this.offset
+
+	switch {
+	case isFixedKind(t.Kind()):
+		b, err := this.take(int(t.Size()), path)
+		if err != nil {
+			return err
+		}
+		if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, v.Addr().Interface()); err != nil {
+			return err
+		}
+	case isByteArray(t):
+		b, err := this.take(t.Len(), path)
+		if err != nil {
+			return err
+		}
+		reflect.Copy(v, reflect.ValueOf(b))
+	case t.Kind() == reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := this.decode(v.Index(i), fmt.Sprintf("%v[%v]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case t.Kind() == reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			fieldPath := joinPath(path, field.Name)
+
+			if field.Name == "_" {
+				size := fixedSize(field.Type)
+				if size < 0 {
+					return errors.New(fmt.Sprintf("Пропускаемое поле [%v] должно быть фиксированного размера", fieldPath))
+				}
+				if _, err := this.take(size, fieldPath); err != nil {
+					return err
+				}
+				if this.visit != nil {
+					this.visit(fieldPath, this.offset-size, size, reflect.Value{})
+				}
+				continue
+			}
+
+			if field.PkgPath != "" {
+				return errors.New(fmt.Sprintf("Поле [%v] не экспортировано", fieldPath))
+			}
+
+			if field.Type.Kind() == reflect.Slice {
+				if err := this.decodeSlice(v, field, v.Field(i), fieldPath); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := this.decode(v.Field(i), fieldPath); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return errors.New(fmt.Sprintf("Тип поля [%v] %v не поддерживается", path, t))
+	}
+
+	if this.visit != nil {
+		this.visit(path, start, this.offset-start, v)
+	}
+
+	return nil
+}
+
+func (this *decoder) decodeSlice(parent reflect.Value, field reflect.StructField, v reflect.Value, path string) error {
+	elemType := field.Type.Elem()
+	count := -1
+
+	if lenField := lenFieldName(field); lenField != "" {
+		counter := parent.FieldByName(lenField)
+		n, ok := intValue(counter)
+		if !counter.IsValid() || !ok {
+			return errors.New(fmt.Sprintf("Поле-счетчик [%v] для [%v] не найдено или не является целым числом", lenField, path))
+		}
+		count = n
+	}
+
+	elems := reflect.MakeSlice(field.Type, 0, 0)
+
+	for i := 0; count < 0 || i < count; i++ {
+		if count < 0 && this.offset >= len(this.data) {
+			break
+		}
+		elem := reflect.New(elemType).Elem()
+		if err := this.decode(elem, fmt.Sprintf("%v[%v]", path, i)); err != nil {
+			return err
+		}
+		elems = reflect.Append(elems, elem)
+	}
+
+	v.Set(elems)
+
+	return nil
+}
+
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// Считать данные пакета "data" в "v".
+//
+// "v" - указатель на значение фиксированного размера или на структуру, которая может содержать срезы (см. описание кодирования)
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("Для чтения данных пакета нужен указатель")
+	}
+
+	d := decoder{data: data}
+
+	return d.decode(rv.Elem(), "")
+}
+
+type encoder struct {
+	buf *bytes.Buffer
+}
+
+func (this *encoder) encode(v reflect.Value, path string) error {
+	t := v.Type()
+
+	switch {
+	case isFixedKind(t.Kind()):
+		return binary.Write(this.buf, binary.LittleEndian, v.Interface())
+	case t.Kind() == reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := this.encode(v.Index(i), fmt.Sprintf("%v[%v]", path, i)); err != nil {
+				return err
+			}
+		}
+	case t.Kind() == reflect.Struct:
+		// значения полей-счетчиков берутся из длинны срезов
+		counters := make(map[string]int)
+		for i := 0; i < t.NumField(); i++ {
+			if field := t.Field(i); field.Type.Kind() == reflect.Slice {
+				if lenField := lenFieldName(field); lenField != "" {
+					counters[lenField] = v.Field(i).Len()
+				}
+			}
+		}
+
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			fieldPath := joinPath(path, field.Name)
+
+			if field.Name == "_" {
+				size := fixedSize(field.Type)
+				if size < 0 {
+					return errors.New(fmt.Sprintf("Пропускаемое поле [%v] должно быть фиксированного размера", fieldPath))
+				}
+				this.buf.Write(make([]byte, size))
+				continue
+			}
+
+			if field.PkgPath != "" {
+				return errors.New(fmt.Sprintf("Поле [%v] не экспортировано", fieldPath))
+			}
+
+			fv := v.Field(i)
+
+			if count, isCounter := counters[field.Name]; isCounter {
+				counter := reflect.New(field.Type).Elem()
+				switch {
+				case counter.Kind() >= reflect.Int8 && counter.Kind() <= reflect.Int64:
+					if counter.OverflowInt(int64(count)) {
+						return errors.New(fmt.Sprintf("Длинна среза не помещается в поле-счетчик [%v]", fieldPath))
+					}
+					counter.SetInt(int64(count))
+				case counter.Kind() >= reflect.Uint8 && counter.Kind() <= reflect.Uint64:
+					if counter.OverflowUint(uint64(count)) {
+						return errors.New(fmt.Sprintf("Длинна среза не помещается в поле-счетчик [%v]", fieldPath))
+					}
+					counter.SetUint(uint64(count))
+				default:
+					return errors.New(fmt.Sprintf("Поле-счетчик [%v] должно быть целым числом", fieldPath))
+				}
+				fv = counter
+			}
+
+			if fv.Kind() == reflect.Slice {
+				for j := 0; j < fv.Len(); j++ {
+					if err := this.encode(fv.Index(j), fmt.Sprintf("%v[%v]", fieldPath, j)); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			if err := this.encode(fv, fieldPath); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New(fmt.Sprintf("Тип поля [%v] %v не поддерживается", path, t))
+	}
+
+	return nil
+}
+
+// Представить "v" в виде данных пакета. Поддерживает те же типы что и Unmarshal, но "v" может быть не указателем.
+//
+// Поля-счетчики срезов заполняются автоматически.
+func Marshal(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, errors.New("Нельзя записать nil в пакет")
+		}
+		rv = rv.Elem()
+	}
+
+	e := encoder{buf: new(bytes.Buffer)}
+
+	if err := e.encode(rv, ""); err != nil {
+		return nil, err
+	}
+
+	if e.buf.Len() > math.MaxUint16-6 {
+		return nil, errors.New("Размер пакета не может превышать 65535 (uint16)")
+	}
+
+	return e.buf.Bytes(), nil
+}
@@ -10,14 +10,25 @@ import (
 var dataCryptKey = resources.PACKET_CRYPT_KEY[6:]
 var dataCryptKeyLength = len(dataCryptKey)
 var headersCryptKey = resources.PACKET_CRYPT_KEY[:6]
-var headersCryptKeyLength = len(dataCryptKey)
+var headersCryptKeyLength = len(headersCryptKey)
+
+// Заменить ключ шифрования пакетов, например ключом, восстановленным по записям трафика (см. CryptKey).
+//
+// Не потоко-безопасно, вызывать нужно до начала работы с пакетами.
+func SetCryptKey(key *CryptKey) {
+	b := key.Bytes()
+	headersCryptKey = b[:headersLength]
+	headersCryptKeyLength = len(headersCryptKey)
+	dataCryptKey = b[headersLength:]
+	dataCryptKeyLength = len(dataCryptKey)
+}
 
 // Кодирует/декодирует данные. Первый вызов кодирует, второй декодирует, или наоборот.
 //
 // Алгоритм простейший, просто XOR-ит все байты ключем шифрования по порядку.
 // В самой R2 это вероятно выполнено по какому-то алгоритму шифрования, я так и не понял по какому, поэтому пока так.
 //
-// Из-за ограниченной длинны ключа (2991 байт), пакеты могут быть расшифрованы/зашифрованы не полностью.
+// Из-за ограниченной длинны ключа (2991 байт, см. CryptKey для его восстановления и дополнения), пакеты могут быть расшифрованы/зашифрованы не полностью.
 func dataCrypt(data []byte) {
 	dLen := len(data)
 
@@ -0,0 +1,294 @@
+package packet
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// Ключ шифрования пакетов (см. dataCrypt) в формате файла resources/packet-crypt.key:
+// первые 6 байт - ключ заголовков, остальные - ключ данных.
+//
+// Так как шифрование - это XOR, i-й байт ключа шифрует i-й байт пакета, поэтому смещения в ключе совпадают со смещениями в пакете.
+//
+// Ключ может быть известен не полностью. Не восстановленные байты записываются в файл нулями, т.е. такие байты пакета не шифруются.
+type CryptKey struct {
+	key   []byte
+	known []bool
+}
+
+// Противоречие при восстановлении ключа: байт ключа, вычисленный по паре пакетов, не совпал с уже известным
+type KeyConflict struct {
+	// Смещение байта в ключе (и в пакете)
+	Offset int
+	// Известное значение байта. Оно остается в ключе
+	Existing byte
+	// Значение, вычисленное по паре пакетов
+	Recovered byte
+}
+
+func (this KeyConflict) String() string {
+	return fmt.Sprintf("Смещение %06d: известно %02x, вычислено %02x", this.Offset, this.Existing, this.Recovered)
+}
+
+// Создает пустой ключ, в котором не известен ни один байт
+func CreateCryptKey() *CryptKey {
+	return &CryptKey{}
+}
+
+// Создает ключ из содержимого файла ключа. Все байты ключа считаются известными
+func ParseCryptKey(b []byte) (*CryptKey, error) {
+	if len(b) < headersLength {
+		return nil, errors.New(fmt.Sprintf("Размер ключа шифрования [%v] меньше размера заголовков пакета [%v]", len(b), headersLength))
+	}
+
+	key := CryptKey{
+		key:   append([]byte{}, b...),
+		known: make([]bool, len(b)),
+	}
+
+	for i := range key.known {
+		key.known[i] = true
+	}
+
+	return &key, nil
+}
+
+// Прочитать ключ из файла (см. ParseCryptKey)
+func ReadCryptKeyFile(path string) (*CryptKey, error) {
+	b, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseCryptKey(b)
+}
+
+// Копия ключа, которым сейчас шифруются пакеты
+func CurrentCryptKey() *CryptKey {
+	key, _ := ParseCryptKey(append(append([]byte{}, headersCryptKey...), dataCryptKey...))
+	return key
+}
+
+// Длинна ключа: смещение последнего известного байта + 1
+func (this *CryptKey) Length() int {
+	for i := len(this.known) - 1; i >= 0; i-- {
+		if this.known[i] {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Кол-во известных байт с начала ключа без пропусков. Пакеты не длиннее этого расшифровываются полностью
+func (this *CryptKey) KnownPrefix() int {
+	for i, known := range this.known {
+		if !known {
+			return i
+		}
+	}
+	return len(this.known)
+}
+
+// Кол-во не известных байт в пределах длинны ключа
+func (this *CryptKey) Unknown() int {
+	count := 0
+	for _, known := range this.known[:this.Length()] {
+		if !known {
+			count += 1
+		}
+	}
+	return count
+}
+
+// Известен ли байт ключа со смещением "offset"
+func (this *CryptKey) Known(offset int) bool {
+	return offset >= 0 && offset < len(this.known) && this.known[offset]
+}
+
+// Ключ в формате файла ключа. Не известные байты записываются нулями
+func (this *CryptKey) Bytes() []byte {
+	length := this.Length()
+
+	if length < headersLength {
+		length = headersLength
+	}
+
+	result := make([]byte, length)
+	copy(result, this.key)
+
+	for i := range this.known {
+		if i < length && !this.known[i] {
+			result[i] = 0
+		}
+	}
+
+	return result
+}
+
+// Записать ключ в файл (см. Bytes). Если файл существует, то он будет перезаписан
+func (this *CryptKey) WriteFile(path string) error {
+	return ioutil.WriteFile(path, this.Bytes(), 0644)
+}
+
+func (this *CryptKey) grow(length int) {
+	for len(this.key) < length {
+		this.key = append(this.key, 0)
+		this.known = append(this.known, false)
+	}
+}
+
+// Установить байт ключа. Если байт уже известен и отличается, то он не меняется, а возвращается противоречие
+func (this *CryptKey) set(offset int, value byte) *KeyConflict {
+	this.grow(offset + 1)
+
+	if this.known[offset] {
+		if this.key[offset] != value {
+			return &KeyConflict{Offset: offset, Existing: this.key[offset], Recovered: value}
+		}
+		return nil
+	}
+
+	this.key[offset] = value
+	this.known[offset] = true
+
+	return nil
+}
+
+// Восстановить байты ключа по зашифрованному пакету и его известному содержимому.
+//
+// "encrypted" - байты зашифрованного пакета в том виде, в котором он был передан по сети, вместе с заголовками.
+//
+// "plain" - байты того же пакета в расшифрованном виде. Флаг шифрования в заголовках должен быть установлен, как в зашифрованном пакете.
+//
+// "mask" - какие байты "plain" известны. Например, если известна только структура пакета (нули в конце строк фиксированной длинны),
+// то остальные байты отмечаются как не известные. nil - известны все байты.
+//
+// Возвращает байты, которые противоречат уже известным. Такие байты в ключе не меняются.
+func (this *CryptKey) AddKnownPlaintext(encrypted []byte, plain []byte, mask []bool) ([]KeyConflict, error) {
+	if len(encrypted) != len(plain) {
+		return nil, errors.New(fmt.Sprintf("Размер зашифрованного пакета [%v] не совпадает с размером расшифрованного [%v]", len(encrypted), len(plain)))
+	}
+
+	if mask != nil && len(mask) != len(plain) {
+		return nil, errors.New(fmt.Sprintf("Размер маски [%v] не совпадает с размером пакета [%v]", len(mask), len(plain)))
+	}
+
+	if len(encrypted) < headersLength || encrypted[2] == 0 {
+		return nil, errors.New("Пакет не зашифрован")
+	}
+
+	conflicts := []KeyConflict{}
+
+	for i := range encrypted {
+		if mask != nil && !mask[i] {
+			continue
+		}
+
+		if conflict := this.set(i, encrypted[i]^plain[i]); conflict != nil {
+			conflicts = append(conflicts, *conflict)
+		}
+	}
+
+	return conflicts, nil
+}
+
+// Восстановить байты ключа по зашифрованному пакету и пакету с известным содержимым, например созданному из документа (см. Document).
+//
+// "encrypted" - байты зашифрованного пакета в том виде, в котором он был передан по сети.
+//
+// "plain" - тот же пакет. Если он зашифрован, то используется его расшифрованная копия.
+//
+// См. AddKnownPlaintext
+func (this *CryptKey) AddPacket(encrypted []byte, plain *Packet) ([]KeyConflict, error) {
+	p := plain.decryptedCopy()
+
+	hb, err := encodePacketHeaders(packetHeaders{
+		Id:        p.Id,
+		Length:    p.length,
+		Num:       p.Num,
+		Encrypted: true,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return this.AddKnownPlaintext(encrypted, append(hb, p.data...), nil)
+}
+
+// Найти период ключа данных: наименьшее "period", при котором все известные байты ключа данных, отстоящие друг от друга на "period", совпадают.
+//
+// "minMatches" - минимальное кол-во сравненных пар байт, при котором период считается найденным. Защищает от случайных совпадений
+// при малом кол-ве известных байт.
+//
+// Возвращает 0, если период не найден.
+func (this *CryptKey) Period(minMatches int) int {
+	if len(this.key) < headersLength {
+		return 0
+	}
+
+	data := this.key[headersLength:]
+	known := this.known[headersLength:]
+
+	for period := 1; period <= len(data)/2; period++ {
+		matches := 0
+		periodic := true
+
+		for i := 0; i+period < len(data); i++ {
+			if !known[i] || !known[i+period] {
+				continue
+			}
+			if data[i] != data[i+period] {
+				periodic = false
+				break
+			}
+			matches += 1
+		}
+
+		if periodic && matches >= minMatches {
+			return period
+		}
+	}
+
+	return 0
+}
+
+// Дополнить ключ данных по периоду (см. Period): не известные байты вычисляются по известным байтам, отстоящим на кратное "period" смещение.
+//
+// "length" - длинна ключа данных, до которой нужно дополнить ключ. Если меньше текущей, то ключ дополняется только внутри текущей длинны.
+//
+// Возвращает кол-во восстановленных байт.
+func (this *CryptKey) ExtendPeriodic(period int, length int) int {
+	if period <= 0 {
+		return 0
+	}
+
+	this.grow(headersLength + length)
+	data := this.key[headersLength:]
+	known := this.known[headersLength:]
+
+	// значения байт для каждого остатка от деления смещения на период
+	phase := make([]byte, period)
+	phaseKnown := make([]bool, period)
+
+	for i := range data {
+		if known[i] && !phaseKnown[i%period] {
+			phase[i%period] = data[i]
+			phaseKnown[i%period] = true
+		}
+	}
+
+	restored := 0
+
+	for i := range data {
+		if !known[i] && phaseKnown[i%period] {
+			data[i] = phase[i%period]
+			known[i] = true
+			restored += 1
+		}
+	}
+
+	return restored
+}
@@ -0,0 +1,356 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Отличие поля пакета
+type FieldDiff struct {
+	// Путь к полю, например "Servers[0].Name"
+	Path string
+	// Значение в первом пакете. Пустое, если поля в нем нет
+	Old string
+	// Значение во втором пакете. Пустое, если поля в нем нет
+	New string
+}
+
+// Результат сравнения двух пакетов. Пакеты сравниваются в расшифрованном виде
+type DiffResult struct {
+	// Первый пакет. nil - пакет есть только во второй последовательности (см. Registry.DiffSequences)
+	A *Packet
+	// Второй пакет. nil - пакет есть только в первой последовательности
+	B *Packet
+	// Отличия заголовков пакетов
+	Headers []string
+	// Смещения отличающихся байт от начала пакета. Байты за концом более короткого пакета тоже считаются отличающимися
+	Bytes []int
+	// Отличия полей, если структура пакета известна
+	Fields []FieldDiff
+
+	registry *Registry
+}
+
+// Совпадают ли пакеты
+func (this *DiffResult) Equal() bool {
+	return this.A != nil && this.B != nil && len(this.Headers) == 0 && len(this.Bytes) == 0
+}
+
+// Краткое описание отличий: заголовки и поля, а если структура пакета не известна - отличающиеся байты
+func (this *DiffResult) Summary() []string {
+	if this.A == nil {
+		return []string{fmt.Sprintf("Лишний пакет %v", this.registry.Name(this.B.Id))}
+	}
+
+	if this.B == nil {
+		return []string{fmt.Sprintf("Нет пакета %v", this.registry.Name(this.A.Id))}
+	}
+
+	result := append([]string{}, this.Headers...)
+
+	if len(this.Fields) > 0 {
+		for _, f := range this.Fields {
+			result = append(result, fmt.Sprintf("%v: %v -> %v", f.Path, orNone(f.Old), orNone(f.New)))
+		}
+		return result
+	}
+
+	a := this.A.Bytes()
+	b := this.B.Bytes()
+
+	for _, offset := range this.Bytes {
+		result = append(result, fmt.Sprintf("Смещение %06d: %v -> %v", offset, byteAt(a, offset), byteAt(b, offset)))
+	}
+
+	return result
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "(нет)"
+	}
+	return value
+}
+
+func byteAt(b []byte, offset int) string {
+	if offset >= len(b) {
+		return "--"
+	}
+	return fmt.Sprintf("%02x", b[offset])
+}
+
+// Представить отличия в виде hex-дампов пакетов рядом друг с другом с отмеченными отличающимися байтами и списка отличий полей
+func (this *DiffResult) String() string {
+	if this.A == nil || this.B == nil {
+		return this.Summary()[0] + "\n"
+	}
+
+	result := fmt.Sprintf("A: %v\nB: %v\n\n", this.registry.Name(this.A.Id), this.registry.Name(this.B.Id))
+
+	if this.Equal() {
+		return result + "Пакеты совпадают\n"
+	}
+
+	for _, h := range this.Headers {
+		result += h + "\n"
+	}
+
+	if len(this.Headers) > 0 {
+		result += "\n"
+	}
+
+	a := this.A.Bytes()
+	b := this.B.Bytes()
+	differs := make(map[int]bool, len(this.Bytes))
+
+	for _, offset := range this.Bytes {
+		differs[offset] = true
+	}
+
+	rowHex := func(data []byte, offset int) string {
+		if offset >= len(data) {
+			return ""
+		}
+		end := offset + 16
+		if end > len(data) {
+			end = len(data)
+		}
+		return fmt.Sprintf("% x", data[offset:end])
+	}
+
+	result += fmt.Sprintf("Offset    %-47s    %v\n\n", "A", "B")
+
+	for offset := 0; offset < len(a) || offset < len(b); offset += 16 {
+		result += fmt.Sprintf("%06d    %-47s    %v\n", offset, rowHex(a, offset), rowHex(b, offset))
+
+		markers := ""
+		for i := offset; i < offset+16; i++ {
+			if differs[i] {
+				markers += "^^ "
+			} else {
+				markers += "   "
+			}
+		}
+
+		if strings.TrimSpace(markers) != "" {
+			result += "          " + strings.TrimRight(markers, " ") + "\n"
+		}
+	}
+
+	if len(this.Fields) > 0 {
+		result += "\n"
+		for _, f := range this.Fields {
+			result += fmt.Sprintf("%v: %v -> %v\n", f.Path, orNone(f.Old), orNone(f.New))
+		}
+	}
+
+	return result
+}
+
+// Значение поля для сравнения. Для полей без значения (пропущенные поля, не разобранный остаток) - hex байт
+func fieldValue(f Field) string {
+	if f.Value.IsValid() {
+		return f.ValueString()
+	}
+	return hex.EncodeToString(f.Raw)
+}
+
+// Сравнить поля пакетов. Возвращает nil, если структура данных не известна или пакеты не удалось разобрать
+func (this *Registry) diffFields(a *Packet, b *Packet) []FieldDiff {
+	if a.Id != b.Id {
+		return nil
+	}
+
+	_, aFields, aErr := Dissect(a, this)
+	_, bFields, bErr := Dissect(b, this)
+
+	if aErr != nil || bErr != nil {
+		return nil
+	}
+
+	bByPath := make(map[string]Field, len(bFields))
+	for _, f := range bFields {
+		bByPath[f.Path] = f
+	}
+
+	result := []FieldDiff{}
+	seen := make(map[string]bool, len(aFields))
+
+	for _, af := range aFields {
+		seen[af.Path] = true
+		bf, exists := bByPath[af.Path]
+
+		if !exists {
+			result = append(result, FieldDiff{Path: af.Path, Old: fieldValue(af)})
+		} else if !bytes.Equal(af.Raw, bf.Raw) {
+			result = append(result, FieldDiff{Path: af.Path, Old: fieldValue(af), New: fieldValue(bf)})
+		}
+	}
+
+	for _, bf := range bFields {
+		if !seen[bf.Path] {
+			result = append(result, FieldDiff{Path: bf.Path, New: fieldValue(bf)})
+		}
+	}
+
+	return result
+}
+
+// Сравнить два пакета, используя описания пакетов из реестра для сравнения полей
+func (this *Registry) Diff(a *Packet, b *Packet) *DiffResult {
+	result := DiffResult{registry: this, A: a, B: b}
+
+	if a == nil || b == nil {
+		return &result
+	}
+
+	if a.encrypted != b.encrypted {
+		result.Headers = append(result.Headers, fmt.Sprintf("Encrypt: %v -> %v", a.encrypted, b.encrypted))
+	}
+
+	a = a.decryptedCopy()
+	b = b.decryptedCopy()
+	result.A, result.B = a, b
+
+	if a.Id != b.Id {
+		result.Headers = append(result.Headers, fmt.Sprintf("ID: %v -> %v", this.Name(a.Id), this.Name(b.Id)))
+	}
+	if a.Num != b.Num {
+		result.Headers = append(result.Headers, fmt.Sprintf("Num: %v -> %v", a.Num, b.Num))
+	}
+	if a.length != b.length {
+		result.Headers = append(result.Headers, fmt.Sprintf("Length: %v -> %v", a.length, b.length))
+	}
+
+	ab := a.Bytes()
+	bb := b.Bytes()
+
+	for i := 0; i < len(ab) || i < len(bb); i++ {
+		if i >= len(ab) || i >= len(bb) || ab[i] != bb[i] {
+			result.Bytes = append(result.Bytes, i)
+		}
+	}
+
+	if len(result.Bytes) > 0 {
+		result.Fields = this.diffFields(a, b)
+	}
+
+	return &result
+}
+
+// Сравнить последовательности пакетов, например две записанные сессии.
+//
+// Пакеты сопоставляются по id так, чтобы совпало как можно больше пакетов (наибольшая общая подпоследовательность),
+// поэтому лишний или пропущенный пакет не сдвигает сравнение остальных. Не сопоставленные пакеты возвращаются с A или B = nil.
+func (this *Registry) DiffSequences(a []*Packet, b []*Packet) []*DiffResult {
+	aIds := make([]uint16, len(a))
+	for i, p := range a {
+		aIds[i] = p.Id
+	}
+
+	bIds := make([]uint16, len(b))
+	for j, p := range b {
+		bIds[j] = p.Id
+	}
+
+	// сессии могут быть длинными, поэтому используется алгоритм Хиршберга с памятью O(len(a) + len(b)) вместо таблицы len(a) x len(b)
+	pairs := [][2]int{}
+	lcsPairs(aIds, bIds, 0, 0, &pairs)
+	// пара-ограничитель после последних пакетов, чтобы вывести не сопоставленные пакеты в конце
+	pairs = append(pairs, [2]int{len(a), len(b)})
+
+	result := []*DiffResult{}
+	i, j := 0, 0
+
+	for _, pair := range pairs {
+		for ; i < pair[0]; i++ {
+			result = append(result, this.Diff(a[i], nil))
+		}
+
+		for ; j < pair[1]; j++ {
+			result = append(result, this.Diff(nil, b[j]))
+		}
+
+		if i < len(a) && j < len(b) {
+			result = append(result, this.Diff(a[i], b[j]))
+			i++
+			j++
+		}
+	}
+
+	return result
+}
+
+// Длинны наибольших общих подпоследовательностей "a" и начал "b": row[k] - для a и b[:k].
+//
+// Если "reverse" = true, то последовательности сравниваются с конца: row[k] - для a и b[len(b)-k:]
+func lcsRow(a []uint16, b []uint16, reverse bool) []int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+
+	for i := range a {
+		x := a[i]
+		if reverse {
+			x = a[len(a)-1-i]
+		}
+
+		for k := 1; k <= len(b); k++ {
+			y := b[k-1]
+			if reverse {
+				y = b[len(b)-k]
+			}
+
+			if x == y {
+				cur[k] = prev[k-1] + 1
+			} else if prev[k] >= cur[k-1] {
+				cur[k] = prev[k]
+			} else {
+				cur[k] = cur[k-1]
+			}
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev
+}
+
+// Найти наибольшую общую подпоследовательность "a" и "b" (алгоритм Хиршберга) и добавить в "pairs" индексы сопоставленных элементов
+// по возрастанию. "aOffset" и "bOffset" - смещения "a" и "b" в исходных последовательностях
+func lcsPairs(a []uint16, b []uint16, aOffset int, bOffset int, pairs *[][2]int) {
+	if len(a) == 0 || len(b) == 0 {
+		return
+	}
+
+	if len(a) == 1 {
+		for k, id := range b {
+			if id == a[0] {
+				*pairs = append(*pairs, [2]int{aOffset, bOffset + k})
+				return
+			}
+		}
+		return
+	}
+
+	mid := len(a) / 2
+	head := lcsRow(a[:mid], b, false)
+	tail := lcsRow(a[mid:], b, true)
+
+	// точка разбиения "b", через которую проходит наибольшая общая подпоследовательность
+	split := 0
+	for k := 0; k <= len(b); k++ {
+		if head[k]+tail[len(b)-k] > head[split]+tail[len(b)-split] {
+			split = k
+		}
+	}
+
+	lcsPairs(a[:mid], b[:split], aOffset, bOffset, pairs)
+	lcsPairs(a[mid:], b[split:], aOffset+mid, bOffset+split, pairs)
+}
+
+// Сравнить два пакета, используя DefaultRegistry (см. Registry.Diff)
+func Diff(a *Packet, b *Packet) *DiffResult {
+	return DefaultRegistry.Diff(a, b)
+}
@@ -0,0 +1,389 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Представление пакета в виде документа JSON/YAML, удобного для ручного редактирования, например:
+//
+//	id: 3101
+//	name: LoginAuthResult
+//	num: 0
+//	encrypted: true
+//	fields:
+//	  AccountId: 1
+//	  Servers:
+//	    - Name: "Server 1"
+//	      Ip: [127, 0, 0, 1]
+//
+// Данные пакета всегда хранятся в расшифрованном виде. "encrypted" означает, что пакет нужно зашифровать при создании.
+// Если структура данных пакета известна, то данные хранятся по полям в "fields", иначе в "data" в виде hex-строки.
+// Массивы байт, похожие на строки, представляются строками, остальные - списками чисел.
+type Document struct {
+	// Id пакета
+	Id uint16 `json:"id" yaml:"id"`
+	// Название пакета из реестра. Только для информации, при создании пакета не используется
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Номер пакета
+	Num uint8 `json:"num" yaml:"num"`
+	// Зашифрован ли пакет
+	Encrypted bool `json:"encrypted" yaml:"encrypted"`
+	// Данные пакета по полям
+	Fields interface{} `json:"fields,omitempty" yaml:"fields,omitempty"`
+	// Данные пакета в виде hex-строки. Пробелы игнорируются. Используется, если не указаны "fields"
+	Data string `json:"data,omitempty" yaml:"data,omitempty"`
+}
+
+// Представить пакет в виде документа, используя описания пакетов из "registry".
+//
+// Если пакет не зарегистрирован или не соответствует описанию, то данные будут представлены hex-строкой.
+// Так же hex-строкой представляются данные, которые не удается в точности восстановить из полей, например, с лишними байтами в конце.
+func (this *Packet) Document(registry *Registry) *Document {
+	doc := Document{
+		Id:        this.Id,
+		Num:       this.Num,
+		Encrypted: this.encrypted,
+	}
+
+	p := this.decryptedCopy()
+	schema, exists := registry.Lookup(p.Id)
+
+	if exists {
+		doc.Name = schema.Name
+		if v, err := registry.Decode(p); err == nil {
+			// Decode не проверяет, что прочитаны все данные, поэтому сверяем их с данными, собранными из полей
+			if b, err := Marshal(reflect.ValueOf(v).Elem().Interface()); err == nil && bytes.Equal(b, p.data) {
+				doc.Fields = toPlain(reflect.ValueOf(v).Elem())
+				return &doc
+			}
+		}
+	}
+
+	doc.Data = hex.EncodeToString(p.data)
+
+	return &doc
+}
+
+// Создать пакет из документа, используя описания пакетов из "registry" для чтения "fields"
+func (this *Document) Packet(registry *Registry) (*Packet, error) {
+	var data []byte
+
+	if this.Fields != nil {
+		schema, exists := registry.Lookup(this.Id)
+
+		if !exists || schema.Type == nil {
+			return nil, errors.New(fmt.Sprintf("Структура данных пакета [%d] не известна, данные нужно указать в \"data\"", this.Id))
+		}
+
+		v := reflect.New(schema.Type).Elem()
+
+		if err := fromPlain(this.Fields, v, schema.Name); err != nil {
+			return nil, err
+		}
+
+		b, err := Marshal(v.Interface())
+
+		if err != nil {
+			return nil, err
+		}
+
+		data = b
+	} else {
+		b, err := hex.DecodeString(strings.Join(strings.Fields(this.Data), ""))
+
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Некорректные данные пакета [%d]: %v", this.Id, err))
+		}
+
+		data = b
+	}
+
+	p, err := CreatePacket(this.Id, data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.Num = this.Num
+
+	if this.Encrypted {
+		p.Encrypt()
+	}
+
+	return p, nil
+}
+
+// Представить пакет в виде JSON документа (см. Document), используя DefaultRegistry
+func (this *Packet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(this.Document(DefaultRegistry))
+}
+
+// Прочитать пакет из JSON документа (см. Document), используя DefaultRegistry
+func (this *Packet) UnmarshalJSON(b []byte) error {
+	doc := Document{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	if err := d.Decode(&doc); err != nil {
+		return err
+	}
+
+	return this.fromDocument(&doc)
+}
+
+// Представить пакет в виде YAML документа (см. Document), используя DefaultRegistry
+func (this *Packet) MarshalYAML() (interface{}, error) {
+	return this.Document(DefaultRegistry), nil
+}
+
+// Прочитать пакет из YAML документа (см. Document), используя DefaultRegistry
+func (this *Packet) UnmarshalYAML(node *yaml.Node) error {
+	doc := Document{}
+
+	if err := node.Decode(&doc); err != nil {
+		return err
+	}
+
+	return this.fromDocument(&doc)
+}
+
+func (this *Packet) fromDocument(doc *Document) error {
+	p, err := doc.Packet(DefaultRegistry)
+
+	if err != nil {
+		return err
+	}
+
+	*this = *p
+
+	return nil
+}
+
+// Поля структуры с сохранением порядка следования
+type plainFields struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (this plainFields) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBufferString("{")
+
+	for i, key := range this.keys {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(this.values[key])
+
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(k)
+		buf.WriteString(":")
+		buf.Write(v)
+	}
+
+	buf.WriteString("}")
+
+	return buf.Bytes(), nil
+}
+
+func (this plainFields) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+
+	for _, key := range this.keys {
+		value := &yaml.Node{}
+
+		if err := value.Encode(this.values[key]); err != nil {
+			return nil, err
+		}
+
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	}
+
+	return node, nil
+}
+
+// Представить массив байт строкой, если он похож на строку из печатных ASCII символов
+func isASCIIString(b []byte) bool {
+	if !isFixedString(b) {
+		return false
+	}
+
+	for _, c := range b {
+		if c > 126 {
+			// строки в cp1251 не переживут преобразование в UTF-8, поэтому оставляем их числами
+			return false
+		}
+	}
+
+	return true
+}
+
+// Представить значение данных пакета в виде значений, которые можно записать в JSON/YAML
+func toPlain(v reflect.Value) interface{} {
+	t := v.Type()
+
+	switch {
+	case isByteArray(t):
+		b := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(b), v)
+		if isASCIIString(b) {
+			return strings.TrimRight(string(b), "\000")
+		}
+		list := make([]interface{}, len(b))
+		for i, c := range b {
+			list[i] = c
+		}
+		return list
+	case t.Kind() == reflect.Array || t.Kind() == reflect.Slice:
+		list := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			list[i] = toPlain(v.Index(i))
+		}
+		return list
+	case t.Kind() == reflect.Struct:
+		fields := plainFields{values: make(map[string]interface{})}
+		for i := 0; i < t.NumField(); i++ {
+			if name := t.Field(i).Name; name != "_" {
+				fields.keys = append(fields.keys, name)
+				fields.values[name] = toPlain(v.Field(i))
+			}
+		}
+		return fields
+	default:
+		return v.Interface()
+	}
+}
+
+// Привести целое число, прочитанное из JSON/YAML, к int64
+func plainInt(src interface{}) (int64, bool) {
+	switch n := src.(type) {
+	case json.Number:
+		i, err := strconv.ParseInt(string(n), 10, 64)
+		return i, err == nil
+	case float64:
+		return int64(n), n == math.Trunc(n) && n >= math.MinInt64 && n <= math.MaxInt64
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint64:
+		return int64(n), n <= math.MaxInt64
+	}
+	return 0, false
+}
+
+// Привести целое неотрицательное число, прочитанное из JSON/YAML, к uint64
+func plainUint(src interface{}) (uint64, bool) {
+	switch n := src.(type) {
+	case json.Number:
+		i, err := strconv.ParseUint(string(n), 10, 64)
+		return i, err == nil
+	case uint64:
+		return n, true
+	}
+	if i, ok := plainInt(src); ok && i >= 0 {
+		return uint64(i), true
+	}
+	return 0, false
+}
+
+// Привести число, прочитанное из JSON/YAML, к float64
+func plainFloat(src interface{}) (float64, bool) {
+	switch n := src.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	}
+	if i, ok := plainInt(src); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+// Записать в "v" значение, прочитанное из JSON/YAML
+func fromPlain(src interface{}, v reflect.Value, path string) error {
+	t := v.Type()
+
+	switch {
+	case t.Kind() == reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			return errors.New(fmt.Sprintf("Поле [%v] должно быть true или false", path))
+		}
+		v.SetBool(b)
+	case t.Kind() >= reflect.Int8 && t.Kind() <= reflect.Int64:
+		n, ok := plainInt(src)
+		if !ok || v.OverflowInt(n) {
+			return errors.New(fmt.Sprintf("Поле [%v] должно быть целым числом типа %v", path, t))
+		}
+		v.SetInt(n)
+	case t.Kind() >= reflect.Uint8 && t.Kind() <= reflect.Uint64:
+		n, ok := plainUint(src)
+		if !ok || v.OverflowUint(n) {
+			return errors.New(fmt.Sprintf("Поле [%v] должно быть целым числом типа %v", path, t))
+		}
+		v.SetUint(n)
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		n, ok := plainFloat(src)
+		if !ok {
+			return errors.New(fmt.Sprintf("Поле [%v] должно быть числом", path))
+		}
+		v.SetFloat(n)
+	case isByteArray(t) && reflect.TypeOf(src) != nil && reflect.TypeOf(src).Kind() == reflect.String:
+		s := src.(string)
+		if len(s) > t.Len() {
+			return errors.New(fmt.Sprintf("Строка в поле [%v] длиннее %v байт", path, t.Len()))
+		}
+		reflect.Copy(v, reflect.ValueOf([]byte(s)))
+	case t.Kind() == reflect.Array || t.Kind() == reflect.Slice:
+		list, ok := src.([]interface{})
+		if !ok {
+			return errors.New(fmt.Sprintf("Поле [%v] должно быть списком", path))
+		}
+		if t.Kind() == reflect.Array && len(list) > t.Len() {
+			return errors.New(fmt.Sprintf("В поле [%v] больше %v элементов", path, t.Len()))
+		}
+		if t.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(t, len(list), len(list)))
+		}
+		for i, item := range list {
+			if err := fromPlain(item, v.Index(i), fmt.Sprintf("%v[%v]", path, i)); err != nil {
+				return err
+			}
+		}
+	case t.Kind() == reflect.Struct:
+		fields, ok := src.(map[string]interface{})
+		if !ok {
+			return errors.New(fmt.Sprintf("Поле [%v] должно быть объектом", path))
+		}
+		for name, item := range fields {
+			field, exists := t.FieldByName(name)
+			if !exists || name == "_" {
+				return errors.New(fmt.Sprintf("Неизвестное поле [%v]", joinPath(path, name)))
+			}
+			if err := fromPlain(item, v.FieldByIndex(field.Index), joinPath(path, name)); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New(fmt.Sprintf("Тип поля [%v] %v не поддерживается", path, t))
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package packet
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Поле пакета, полученное при разборе данных пакета по его описанию
+type Field struct {
+	// Путь к полю, например "Servers[0].Name"
+	Path string
+	// Смещение поля от начала пакета (включая заголовки)
+	Offset int
+	// Размер поля в байтах
+	Size int
+	// Байты поля
+	Raw []byte
+	// Прочитанное значение. Не валидно (IsValid() = false) для пропущенных полей ("_") и не разобранного остатка пакета
+	Value reflect.Value
+}
+
+// Представить значение поля в виде строки
+func (this *Field) ValueString() string {
+	if !this.Value.IsValid() {
+		return ""
+	}
+
+	v := this.Value
+
+	switch {
+	case isByteArray(v.Type()):
+		b := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(b), v)
+		return formatBytes(b)
+	case v.Kind() == reflect.Bool:
+		return fmt.Sprint(v.Bool())
+	case v.Kind() >= reflect.Int8 && v.Kind() <= reflect.Int64:
+		return fmt.Sprint(v.Int())
+	case v.Kind() >= reflect.Uint8 && v.Kind() <= reflect.Uint64:
+		return fmt.Sprint(v.Uint())
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// Похож ли массив байт на строку фиксированной длинны: печатные символы (включая кириллицу в cp1251), за которыми идут только нули
+func isFixedString(b []byte) bool {
+	end := len(b)
+	for end > 0 && b[end-1] == 0 {
+		end--
+	}
+
+	if end == 0 {
+		return true
+	}
+
+	for _, c := range b[:end] {
+		if c < 32 || c == 127 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Представить массив байт строкой в кавычках, если он похож на строку, или списком чисел
+func formatBytes(b []byte) string {
+	if isFixedString(b) {
+		return fmt.Sprintf("%q", strings.TrimRight(string(b), "\000"))
+	}
+	return fmt.Sprint(b)
+}
+
+// Разобрать данные пакета по описанию из реестра.
+//
+// Возвращает описание пакета и список конечных полей (числа, bool, массивы байт, пропущенные поля) в порядке следования.
+// Если после разбора в пакете остались данные, то они будут последним полем с путем "(не разобрано)".
+func Dissect(p *Packet, registry *Registry) (Schema, []Field, error) {
+	schema, exists := registry.Lookup(p.Id)
+
+	if !exists {
+		return schema, nil, errors.New(fmt.Sprintf("Пакет [%d] не зарегистрирован", p.Id))
+	}
+
+	if schema.Type == nil {
+		return schema, nil, errors.New(fmt.Sprintf("Структура данных пакета %v не известна", schema.String()))
+	}
+
+	if p.IsEncrypted() {
+		return schema, nil, errors.New(fmt.Sprintf("Пакет %v зашифрован", schema.String()))
+	}
+
+	fields := []Field{}
+	d := decoder{
+		data: p.data,
+		visit: func(path string, offset int, size int, v reflect.Value) {
+			fields = append(fields, Field{
+				Path:   path,
+				Offset: headersLength + offset,
+				Size:   size,
+				Raw:    p.data[offset : offset+size],
+				Value:  v,
+			})
+		},
+	}
+
+	if err := d.decode(reflect.New(schema.Type).Elem(), ""); err != nil {
+		return schema, fields, err
+	}
+
+	if d.offset < len(p.data) {
+		fields = append(fields, Field{
+			Path:   "(не разобрано)",
+			Offset: headersLength + d.offset,
+			Size:   len(p.data) - d.offset,
+			Raw:    p.data[d.offset:],
+		})
+	}
+
+	return schema, fields, nil
+}
+
+// Представить пакет в строковом виде, используя описания пакетов из "registry".
+//
+// Для известных расшифрованных пакетов выводится таблица полей со смещением, размером, значением и байтами, иначе hex-дамп.
+func (this *Packet) Dump(registry *Registry) string {
+	result := this.headersString(registry)
+	schema, fields, err := Dissect(this, registry)
+
+	if err != nil {
+		if schema.Type != nil && !this.encrypted {
+			// описание есть, но пакет ему не соответствует
+			result += fmt.Sprintf("Не удалось разобрать пакет: %v\n\n", err)
+		}
+		return result + this.hexDump()
+	}
+
+	result += fmt.Sprintf("Direction: %v\n\n", schema.Direction)
+
+	pathWidth := len("Field")
+	valueWidth := len("Value")
+
+	for _, f := range fields {
+		if len(f.Path) > pathWidth {
+			pathWidth = len(f.Path)
+		}
+		if l := len(f.ValueString()); l > valueWidth && l <= 48 {
+			valueWidth = l
+		}
+	}
+
+	row := fmt.Sprintf("%%06d    %%5d    %%-%ds    %%-%ds    %%s\n", pathWidth, valueWidth)
+	result += fmt.Sprintf(fmt.Sprintf("Offset     Size    %%-%ds    %%-%ds    Bytes\n\n", pathWidth, valueWidth), "Field", "Value")
+
+	for _, f := range fields {
+		raw := f.Raw
+		suffix := ""
+		if len(raw) > 16 {
+			raw = raw[:16]
+			suffix = " ..."
+		}
+		result += fmt.Sprintf(row, f.Offset, f.Size, f.Path, f.ValueString(), fmt.Sprintf("% x", raw)+suffix)
+	}
+
+	return result
+}
@@ -6,6 +6,9 @@ import (
 	"errors"
 )
 
+// Размер заголовков пакета в байтах
+const headersLength = 6
+
 // Заголовки пакета.
 //
 // Последовательность полей должна быть такая, нельзя менять, иначе могут быть проблемы с чтением/записью
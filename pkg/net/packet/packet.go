@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"strings"
 )
@@ -36,6 +37,14 @@ func (this *Packet) Decrypt() {
 	}
 }
 
+// Расшифрованная копия пакета. Исходный пакет не меняется
+func (this *Packet) decryptedCopy() *Packet {
+	p := *this
+	p.data = append([]byte{}, this.data...)
+	p.Decrypt()
+	return &p
+}
+
 // Зашифрован ли пакет
 func (this *Packet) IsEncrypted() bool {
 	return this.encrypted
@@ -51,14 +60,25 @@ func (this *Packet) Hex() string {
 	return hex.EncodeToString(this.Bytes())
 }
 
-// Представить пакет в строковом виде
+// Представить пакет в строковом виде.
+//
+// Если пакет зарегистрирован в DefaultRegistry и расшифрован, то выводится разбор данных пакета по полям, иначе hex-дамп.
 func (this *Packet) String() string {
-	result := fmt.Sprintf("ID:       %v\n", this.Id)
+	return this.Dump(DefaultRegistry)
+}
+
+func (this *Packet) headersString(registry *Registry) string {
+	result := fmt.Sprintf("ID:       %v\n", registry.Name(this.Id))
 	result += fmt.Sprintf("Length:   %v\n", this.length)
 	result += fmt.Sprintf("Encrypt:  %v\n", this.encrypted)
 	result += fmt.Sprintf("Num:      %v\n", this.Num)
 	result += "\n"
-	result += "Offset    01 02 03 04 05 06 07 08 09 10 11 12 13 14 15 16    ASCII\n\n"
+	return result
+}
+
+// Представить байты пакета в виде hex-дампа
+func (this *Packet) hexDump() string {
+	result := "Offset    01 02 03 04 05 06 07 08 09 10 11 12 13 14 15 16    ASCII\n\n"
 
 	b := this.Bytes()
 	bLen := len(b)
@@ -194,6 +214,35 @@ func CreatePacketFromBytesOrPanic(b []byte) *Packet {
 	return p
 }
 
+// Считывает из "r" один пакет: 2 байта длинны пакета, затем остальные байты пакета.
+//
+// Пакет возвращается в том виде, в котором был получен (если он был зашифрован, то останется зашифрованным).
+// Если поток закончился до начала пакета, то возвращается io.EOF.
+func ReadPacket(r io.Reader) (*Packet, error) {
+	bufLen := make([]byte, 2)
+
+	if _, err := io.ReadFull(r, bufLen); err != nil {
+		return nil, err
+	}
+
+	pLen := binary.LittleEndian.Uint16(bufLen)
+
+	if pLen < 6 {
+		return nil, errors.New(fmt.Sprintf("Некорректная длинна пакета [%v]. Минимальный размер пакета 6 байт", pLen))
+	}
+
+	bufPac := make([]byte, pLen-2)
+
+	if _, err := io.ReadFull(r, bufPac); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return CreatePacketFromBytes(append(bufLen, bufPac...))
+}
+
 // Создает пакет из hex-строки
 func CreatePacketFromHexString(hexStr string) (*Packet, error) {
 	b, err := hex.DecodeString(hexStr)
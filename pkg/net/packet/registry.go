@@ -0,0 +1,176 @@
+package packet
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Направление пакета
+type Direction uint8
+
+const (
+	DIRECTION_UNKNOWN Direction = iota
+	// Клиент -> сервер
+	DIRECTION_CLIENT_TO_SERVER
+	// Сервер -> клиент
+	DIRECTION_SERVER_TO_CLIENT
+)
+
+func (this Direction) String() string {
+	switch this {
+	case DIRECTION_CLIENT_TO_SERVER:
+		return "C->S"
+	case DIRECTION_SERVER_TO_CLIENT:
+		return "S->C"
+	default:
+		return "?"
+	}
+}
+
+// Описание пакета
+type Schema struct {
+	// Id пакета
+	Id uint16
+	// Название пакета
+	Name string
+	// Направление пакета
+	Direction Direction
+	// Тип данных пакета (см. Unmarshal). nil - структура данных пакета не известна
+	Type reflect.Type
+}
+
+// Название пакета вместе с id, например "LoginAuthResult(3101)"
+func (this *Schema) String() string {
+	return fmt.Sprintf("%v(%d)", this.Name, this.Id)
+}
+
+// Создать пустое значение данных пакета. Возвращает nil, если тип данных не известен
+func (this *Schema) New() interface{} {
+	if this.Type == nil {
+		return nil
+	}
+	return reflect.New(this.Type).Interface()
+}
+
+// Потоко-безопасный реестр описаний пакетов
+type Registry struct {
+	schemas map[uint16]Schema
+	mu      sync.RWMutex
+}
+
+// Зарегистрировать пакет
+//
+// "id" - id пакета
+//
+// "name" - название пакета
+//
+// "direction" - направление пакета
+//
+// "payload" - значение или указатель на значение типа данных пакета. nil - если структура данных пакета не известна
+func (this *Registry) Register(id uint16, name string, direction Direction, payload interface{}) error {
+	schema := Schema{
+		Id:        id,
+		Name:      name,
+		Direction: direction,
+	}
+
+	if payload != nil {
+		t := reflect.TypeOf(payload)
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		schema.Type = t
+	}
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if existing, exists := this.schemas[id]; exists {
+		return errors.New(fmt.Sprintf("Пакет [%d] уже зарегистрирован как %v", id, existing.Name))
+	}
+
+	this.schemas[id] = schema
+
+	return nil
+}
+
+// Обертка над Register, вызывающая панику в случае ошибки.
+func (this *Registry) RegisterOrPanic(id uint16, name string, direction Direction, payload interface{}) {
+	if err := this.Register(id, name, direction, payload); err != nil {
+		panic(err)
+	}
+}
+
+// Получить описание пакета
+func (this *Registry) Lookup(id uint16) (Schema, bool) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	schema, exists := this.schemas[id]
+	return schema, exists
+}
+
+// Название пакета вместе с id, например "LoginAuthResult(3101)", или просто id если пакет не зарегистрирован
+func (this *Registry) Name(id uint16) string {
+	if schema, exists := this.Lookup(id); exists {
+		return schema.String()
+	}
+	return fmt.Sprint(id)
+}
+
+// Получить описания всех пакетов, отсортированные по id
+func (this *Registry) Schemas() []Schema {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	result := make([]Schema, 0, len(this.schemas))
+
+	for _, schema := range this.schemas {
+		result = append(result, schema)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+
+	return result
+}
+
+// Прочитать данные пакета в значение зарегистрированного для него типа.
+//
+// Возвращает указатель на значение. Пакет должен быть расшифрован.
+func (this *Registry) Decode(p *Packet) (interface{}, error) {
+	schema, exists := this.Lookup(p.Id)
+
+	if !exists {
+		return nil, errors.New(fmt.Sprintf("Пакет [%d] не зарегистрирован", p.Id))
+	}
+
+	if schema.Type == nil {
+		return nil, errors.New(fmt.Sprintf("Структура данных пакета %v не известна", schema.String()))
+	}
+
+	if p.IsEncrypted() {
+		return nil, errors.New(fmt.Sprintf("Пакет %v зашифрован", schema.String()))
+	}
+
+	v := schema.New()
+
+	if err := Unmarshal(p.data, v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// Создает пустой реестр пакетов
+func CreateRegistry() *Registry {
+	return &Registry{
+		schemas: make(map[uint16]Schema),
+	}
+}
+
+// Общий реестр пакетов. В нем регистрируют свои пакеты пакеты библиотеки (net, login и т.д.)
+var DefaultRegistry = CreateRegistry()
@@ -0,0 +1,34 @@
+package net
+
+import "github.com/tuxuuman/r2o-core/pkg/net/packet"
+
+const (
+	// Обычная ошибка (ErrorPacket)
+	PACKET_ERROR uint16 = 1102
+	// Разрешение подключения. Содержимое не известно, отправляется как есть из resources/acp.r2pac
+	PACKET_ACCEPT_CONNECTION uint16 = 1103
+	// Критическая ошибка, после которой клиент отключается (FatalErrorPacket)
+	PACKET_FATAL_ERROR uint16 = 3102
+)
+
+// Пакет с обычной ошибкой
+type ErrorPacket struct {
+	// id пакета, в ответ на который возникла ошибка
+	PacketId uint16
+	// id ошибки
+	ErrorId uint32
+	// дополнительный код, отображаемый рядом с текстом ошибки
+	Code uint32
+}
+
+// Пакет с критической ошибкой
+type FatalErrorPacket struct {
+	// id ошибки
+	ErrorId uint32
+}
+
+func init() {
+	packet.DefaultRegistry.RegisterOrPanic(PACKET_ERROR, "Error", packet.DIRECTION_SERVER_TO_CLIENT, ErrorPacket{})
+	packet.DefaultRegistry.RegisterOrPanic(PACKET_ACCEPT_CONNECTION, "AcceptConnection", packet.DIRECTION_SERVER_TO_CLIENT, nil)
+	packet.DefaultRegistry.RegisterOrPanic(PACKET_FATAL_ERROR, "FatalError", packet.DIRECTION_SERVER_TO_CLIENT, FatalErrorPacket{})
+}
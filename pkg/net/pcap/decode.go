@@ -0,0 +1,172 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	gonet "net"
+)
+
+// Типы канального уровня (LINKTYPE_*), которые поддерживает чтение
+const (
+	LINKTYPE_NULL       uint32 = 0
+	LINKTYPE_ETHERNET   uint32 = 1
+	LINKTYPE_RAW        uint32 = 101
+	LINKTYPE_LOOP       uint32 = 108
+	LINKTYPE_LINUX_SLL  uint32 = 113
+	LINKTYPE_IPV4       uint32 = 228
+	LINKTYPE_IPV6       uint32 = 229
+	LINKTYPE_LINUX_SLL2 uint32 = 276
+)
+
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+	etherTypeQinQ = 0x88a8
+
+	ipProtocolTCP = 6
+)
+
+// Флаг SYN заголовка TCP
+const tcpFlagSYN = 0x02
+
+// TCP сегмент
+type segment struct {
+	srcIp   gonet.IP
+	dstIp   gonet.IP
+	srcPort uint16
+	dstPort uint16
+	seq     uint32
+	flags   uint8
+	payload []byte
+}
+
+func (this *segment) src() string {
+	return gonet.JoinHostPort(this.srcIp.String(), fmt.Sprint(this.srcPort))
+}
+
+func (this *segment) dst() string {
+	return gonet.JoinHostPort(this.dstIp.String(), fmt.Sprint(this.dstPort))
+}
+
+// Получить IP пакет из кадра канального уровня. Возвращает nil, если кадр не содержит IP пакет
+func linkPayload(linkType uint32, data []byte) []byte {
+	switch linkType {
+	case LINKTYPE_ETHERNET:
+		if len(data) < 14 {
+			return nil
+		}
+		etherType := binary.BigEndian.Uint16(data[12:])
+		data = data[14:]
+		for (etherType == etherTypeVLAN || etherType == etherTypeQinQ) && len(data) >= 4 {
+			etherType = binary.BigEndian.Uint16(data[2:])
+			data = data[4:]
+		}
+		if etherType != etherTypeIPv4 && etherType != etherTypeIPv6 {
+			return nil
+		}
+		return data
+	case LINKTYPE_NULL, LINKTYPE_LOOP:
+		// семейство адресов в порядке байт записавшей машины, поэтому версию IP проверяем по самому пакету
+		if len(data) < 4 {
+			return nil
+		}
+		return data[4:]
+	case LINKTYPE_RAW, LINKTYPE_IPV4, LINKTYPE_IPV6:
+		return data
+	case LINKTYPE_LINUX_SLL:
+		if len(data) < 16 {
+			return nil
+		}
+		return data[16:]
+	case LINKTYPE_LINUX_SLL2:
+		if len(data) < 20 {
+			return nil
+		}
+		return data[20:]
+	}
+	return nil
+}
+
+// Разобрать IP пакет и получить из него TCP сегмент. Возвращает false, если это не TCP или пакет фрагментирован
+func parseIP(data []byte) (segment, bool) {
+	seg := segment{}
+
+	if len(data) < 1 {
+		return seg, false
+	}
+
+	var payload []byte
+
+	switch data[0] >> 4 {
+	case 4:
+		if len(data) < 20 {
+			return seg, false
+		}
+
+		headerLen := int(data[0]&0x0f) * 4
+		totalLen := int(binary.BigEndian.Uint16(data[2:]))
+		fragment := binary.BigEndian.Uint16(data[6:])
+
+		// фрагментированные пакеты не собираем, R2 их не использует
+		if fragment&0x3fff != 0 || data[9] != ipProtocolTCP || headerLen < 20 || totalLen < headerLen || totalLen > len(data) {
+			return seg, false
+		}
+
+		seg.srcIp = gonet.IP(data[12:16])
+		seg.dstIp = gonet.IP(data[16:20])
+		payload = data[headerLen:totalLen]
+	case 6:
+		if len(data) < 40 {
+			return seg, false
+		}
+
+		next := data[6]
+		end := 40 + int(binary.BigEndian.Uint16(data[4:]))
+
+		if end > len(data) {
+			return seg, false
+		}
+
+		seg.srcIp = gonet.IP(data[8:24])
+		seg.dstIp = gonet.IP(data[24:40])
+		payload = data[40:end]
+
+		// пропускаем заголовки расширений hop-by-hop, routing и destination options
+		for next == 0 || next == 43 || next == 60 {
+			if len(payload) < 8 {
+				return seg, false
+			}
+			extLen := (int(payload[1]) + 1) * 8
+			if extLen > len(payload) {
+				return seg, false
+			}
+			next = payload[0]
+			payload = payload[extLen:]
+		}
+
+		if next != ipProtocolTCP {
+			return seg, false
+		}
+	default:
+		return seg, false
+	}
+
+	if len(payload) < 20 {
+		return seg, false
+	}
+
+	dataOffset := int(payload[12]>>4) * 4
+
+	if dataOffset < 20 || dataOffset > len(payload) {
+		return seg, false
+	}
+
+	seg.srcPort = binary.BigEndian.Uint16(payload)
+	seg.dstPort = binary.BigEndian.Uint16(payload[2:])
+	seg.seq = binary.BigEndian.Uint32(payload[4:])
+	seg.flags = payload[13]
+	seg.payload = payload[dataOffset:]
+
+	return seg, true
+}
@@ -0,0 +1,297 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+// Данные одного направления TCP соединения
+type stream struct {
+	started bool
+	nextSeq uint32
+	// сегменты, пришедшие раньше предыдущих
+	pending map[uint32][]byte
+	// собранные данные, еще не разбитые на пакеты
+	buf []byte
+	// поток не удалось разбить на пакеты, остальные данные игнорируются
+	broken bool
+}
+
+type connection struct {
+	id      uint32
+	name    string
+	streams [2]*stream
+}
+
+// Сборка TCP соединений и разбиение их на пакеты R2
+type extractor struct {
+	port        uint16
+	connections map[string]*connection
+	lastConnId  uint32
+	records     []packet.Record
+}
+
+func directionIndex(direction packet.Direction) int {
+	if direction == packet.DIRECTION_CLIENT_TO_SERVER {
+		return 0
+	}
+	return 1
+}
+
+func (this *extractor) handleFrame(f frame) {
+	ipData := linkPayload(f.linkType, f.data)
+
+	if ipData == nil {
+		return
+	}
+
+	seg, ok := parseIP(ipData)
+
+	if !ok {
+		return
+	}
+
+	var direction packet.Direction
+	var key string
+
+	switch this.port {
+	case seg.dstPort:
+		direction = packet.DIRECTION_CLIENT_TO_SERVER
+		key = seg.src() + " -> " + seg.dst()
+	case seg.srcPort:
+		direction = packet.DIRECTION_SERVER_TO_CLIENT
+		key = seg.dst() + " -> " + seg.src()
+	default:
+		return
+	}
+
+	conn, exists := this.connections[key]
+	s := conn.stream(direction)
+
+	// SYN в уже начатом направлении (кроме повторной передачи) означает новое соединение с тем же адресом и портом
+	if !exists || (seg.flags&tcpFlagSYN != 0 && s.started && seg.seq+1 != s.nextSeq) {
+		if exists {
+			conn.finish()
+		}
+		this.lastConnId += 1
+		conn = &connection{id: this.lastConnId, name: key}
+		conn.streams[0] = &stream{pending: make(map[uint32][]byte)}
+		conn.streams[1] = &stream{pending: make(map[uint32][]byte)}
+		this.connections[key] = conn
+		s = conn.stream(direction)
+	}
+
+	if seg.flags&tcpFlagSYN != 0 {
+		s.started = true
+		s.nextSeq = seg.seq + 1
+		return
+	}
+
+	if len(seg.payload) == 0 || s.broken {
+		return
+	}
+
+	if !s.started {
+		// начало соединения не попало в запись
+		s.started = true
+		s.nextSeq = seg.seq
+	}
+
+	s.add(seg.seq, seg.payload)
+
+	for {
+		frameData, err := s.nextFrame()
+
+		if err != "" {
+			log.Printf("Соединение [%v] %v (%v): %v. Остальные данные этого направления пропущены", conn.id, conn.name, direction, err)
+			s.broken = true
+			break
+		}
+
+		if frameData == nil {
+			break
+		}
+
+		this.records = append(this.records, packet.Record{
+			Time:      f.time,
+			Direction: direction,
+			ConnId:    conn.id,
+			Data:      frameData,
+		})
+	}
+}
+
+func (this *connection) stream(direction packet.Direction) *stream {
+	if this == nil {
+		return nil
+	}
+	return this.streams[directionIndex(direction)]
+}
+
+// Сообщить о данных, которые не удалось разбить на пакеты
+func (this *connection) finish() {
+	for i, s := range this.streams {
+		direction := packet.DIRECTION_CLIENT_TO_SERVER
+		if i == 1 {
+			direction = packet.DIRECTION_SERVER_TO_CLIENT
+		}
+
+		if len(s.pending) > 0 {
+			log.Printf("Соединение [%v] %v (%v): в записи не хватает сегментов, %v сегментов не удалось собрать", this.id, this.name, direction, len(s.pending))
+		}
+
+		if len(s.buf) > 0 && !s.broken {
+			log.Printf("Соединение [%v] %v (%v): оборванный пакет в конце соединения (%v байт)", this.id, this.name, direction, len(s.buf))
+		}
+	}
+}
+
+// Добавить данные сегмента с порядковым номером "seq"
+func (this *stream) add(seq uint32, data []byte) {
+	// разница с учетом переполнения порядкового номера
+	diff := int32(seq - this.nextSeq)
+
+	if diff > 0 {
+		if existing, exists := this.pending[seq]; !exists || len(existing) < len(data) {
+			this.pending[seq] = append([]byte{}, data...)
+		}
+		return
+	}
+
+	this.append(seq, data)
+
+	// добавляем сегменты, которые теперь идут по порядку
+	for applied := true; applied; {
+		applied = false
+		for pendingSeq, pendingData := range this.pending {
+			if int32(pendingSeq-this.nextSeq) <= 0 {
+				delete(this.pending, pendingSeq)
+				this.append(pendingSeq, pendingData)
+				applied = true
+			}
+		}
+	}
+}
+
+// Добавить данные, начинающиеся не позже nextSeq. Повторно переданные байты отбрасываются
+func (this *stream) append(seq uint32, data []byte) {
+	skip := int(this.nextSeq - seq)
+
+	if skip >= len(data) {
+		return
+	}
+
+	this.buf = append(this.buf, data[skip:]...)
+	this.nextSeq += uint32(len(data) - skip)
+}
+
+// Отделить от собранных данных следующий пакет так же, как это делает packet.ReadPacket.
+//
+// Возвращает nil, если данных для пакета пока не достаточно, или описание ошибки, если данные не похожи на пакет.
+func (this *stream) nextFrame() ([]byte, string) {
+	if len(this.buf) < 2 {
+		return nil, ""
+	}
+
+	length := int(binary.LittleEndian.Uint16(this.buf))
+
+	if length < 6 {
+		return nil, fmt.Sprintf("некорректная длинна пакета [%v]", length)
+	}
+
+	if len(this.buf) < length {
+		return nil, ""
+	}
+
+	data := append([]byte{}, this.buf[:length]...)
+	this.buf = this.buf[length:]
+
+	return data, ""
+}
+
+// Извлечь пакеты R2 из записи tcpdump в формате pcap или pcapng.
+//
+// "port" - порт сервера. Пакеты, отправленные на этот порт, считаются пакетами клиента (C->S), отправленные с него - пакетами сервера (S->C).
+//
+// TCP соединения собираются с учетом порядка сегментов и повторных передач, и разбиваются на пакеты по их длинне.
+// Пакеты возвращаются в том виде, в котором были переданы: зашифрованные пакеты не расшифровываются. Расшифровать их можно через DecryptRecord
+// или сразу извлечь расшифрованные пакеты через ExtractPackets. Каждому соединению назначается свой ConnId.
+// Проблемы со сборкой соединений (потерянные сегменты, некорректные пакеты) выводятся в лог и не прерывают чтение.
+func Extract(r io.Reader, port uint16) ([]packet.Record, error) {
+	e := extractor{
+		port:        port,
+		connections: make(map[string]*connection),
+	}
+
+	if err := readFrames(r, e.handleFrame); err != nil {
+		return e.records, err
+	}
+
+	for _, conn := range e.connections {
+		conn.finish()
+	}
+
+	return e.records, nil
+}
+
+// Извлечь пакеты R2 из файла записи tcpdump (см. Extract)
+func ExtractFile(path string, port uint16) ([]packet.Record, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return Extract(f, port)
+}
+
+// Извлечь пакеты R2 из записи tcpdump (см. Extract) и расшифровать их.
+//
+// Пакеты возвращаются в порядке передачи, без времени, направления и соединения. Если они нужны, используйте Extract и DecryptRecord
+func ExtractPackets(r io.Reader, port uint16) ([]*packet.Packet, error) {
+	records, err := Extract(r, port)
+	packets := make([]*packet.Packet, 0, len(records))
+
+	for _, rec := range records {
+		p, decryptErr := DecryptRecord(rec)
+
+		if decryptErr != nil {
+			return packets, decryptErr
+		}
+
+		packets = append(packets, p)
+	}
+
+	return packets, err
+}
+
+// Создать из записи пакет и расшифровать его
+func DecryptRecord(rec packet.Record) (*packet.Packet, error) {
+	p, err := rec.Packet()
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.Decrypt()
+
+	return p, nil
+}
+
+// Записать извлеченные пакеты в запись трафика .r2pac. ConnId пакетов сохраняются как есть
+func WriteCapture(w *packet.CaptureWriter, records []packet.Record) error {
+	for _, rec := range records {
+		if err := w.Write(rec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,293 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Чтение файлов tcpdump/wireshark в форматах pcap и pcapng.
+//
+// Читаются только захваченные кадры канального уровня, остальные блоки pcapng (статистика, имена и т.д.) пропускаются.
+
+const (
+	pcapMagicMicro    uint32 = 0xa1b2c3d4
+	pcapMagicNano     uint32 = 0xa1b23c4d
+	pcapngSectionType uint32 = 0x0a0d0d0a
+	pcapngByteOrder   uint32 = 0x1a2b3c4d
+
+	pcapngInterfaceBlock      uint32 = 1
+	pcapngSimplePacketBlock   uint32 = 3
+	pcapngEnhancedPacketBlock uint32 = 6
+
+	// опция if_tsresol блока описания интерфейса
+	pcapngOptionTsResol uint16 = 9
+	pcapngOptionEnd     uint16 = 0
+
+	// Максимальная длинна кадра. Длинна кадра читается из файла, поэтому ограничивается до выделения памяти под него.
+	// С запасом больше snaplen по умолчанию у tcpdump и wireshark (262144)
+	maxFrameLength uint32 = 1 << 20
+	// Максимальная длинна блока pcapng. Кроме кадра блок может содержать опции
+	maxBlockLength uint32 = 1 << 24
+)
+
+// Кадр канального уровня
+type frame struct {
+	time     time.Time
+	linkType uint32
+	data     []byte
+}
+
+type interfaceInfo struct {
+	linkType uint32
+	tsResol  uint8
+}
+
+// Преобразовать метку времени в единицах "resol" (как в if_tsresol) во время
+func tsToTime(ts uint64, resol uint8) time.Time {
+	if resol&0x80 != 0 {
+		// степень двойки
+		exp := uint(resol & 0x7f)
+		sec := ts >> exp
+		frac := ts & (1<<exp - 1)
+		return time.Unix(int64(sec), int64(float64(frac)/float64(uint64(1)<<exp)*1e9))
+	}
+
+	div := uint64(1)
+	for i := uint8(0); i < resol; i++ {
+		div *= 10
+	}
+
+	sec := ts / div
+	frac := ts % div
+
+	if resol <= 9 {
+		for i := resol; i < 9; i++ {
+			frac *= 10
+		}
+	} else {
+		for i := uint8(9); i < resol; i++ {
+			frac /= 10
+		}
+	}
+
+	return time.Unix(int64(sec), int64(frac))
+}
+
+// Прочитать все кадры из pcap или pcapng и передать их в "handle"
+func readFrames(r io.Reader, handle func(f frame)) error {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+
+	if err != nil {
+		return errors.New(fmt.Sprintf("Не удалось прочитать заголовок файла: %v", err))
+	}
+
+	switch {
+	case binary.LittleEndian.Uint32(magic) == pcapngSectionType:
+		return readPcapng(br, handle)
+	case binary.LittleEndian.Uint32(magic) == pcapMagicMicro || binary.LittleEndian.Uint32(magic) == pcapMagicNano:
+		return readPcap(br, binary.LittleEndian, handle)
+	case binary.BigEndian.Uint32(magic) == pcapMagicMicro || binary.BigEndian.Uint32(magic) == pcapMagicNano:
+		return readPcap(br, binary.BigEndian, handle)
+	}
+
+	return errors.New("Файл не является pcap или pcapng")
+}
+
+func readPcap(r io.Reader, order binary.ByteOrder, handle func(f frame)) error {
+	header := make([]byte, 24)
+
+	if _, err := io.ReadFull(r, header); err != nil {
+		return errors.New(fmt.Sprintf("Не удалось прочитать заголовок pcap: %v", err))
+	}
+
+	resol := uint8(6)
+	if order.Uint32(header) == pcapMagicNano {
+		resol = 9
+	}
+
+	linkType := order.Uint32(header[20:])
+	recHeader := make([]byte, 16)
+
+	snapLen := order.Uint32(header[16:])
+	if snapLen == 0 || snapLen > maxFrameLength {
+		snapLen = maxFrameLength
+	}
+
+	for {
+		if _, err := io.ReadFull(r, recHeader); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return errors.New(fmt.Sprintf("pcap обрывается на заголовке кадра: %v", err))
+		}
+
+		sec := uint64(order.Uint32(recHeader))
+		frac := uint64(order.Uint32(recHeader[4:]))
+		length := order.Uint32(recHeader[8:])
+
+		if length > snapLen {
+			return errors.New(fmt.Sprintf("Длинна кадра pcap [%v] больше допустимой [%v]", length, snapLen))
+		}
+
+		data := make([]byte, length)
+
+		if _, err := io.ReadFull(r, data); err != nil {
+			return errors.New(fmt.Sprintf("pcap обрывается на данных кадра: %v", err))
+		}
+
+		div := uint64(1000000)
+		if resol == 9 {
+			div = 1000000000
+		}
+
+		handle(frame{
+			time:     tsToTime(sec*div+frac, resol),
+			linkType: linkType,
+			data:     data,
+		})
+	}
+}
+
+func readPcapng(r io.Reader, handle func(f frame)) error {
+	var order binary.ByteOrder = binary.LittleEndian
+	interfaces := []interfaceInfo{}
+	head := make([]byte, 8)
+
+	for {
+		if _, err := io.ReadFull(r, head); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return errors.New(fmt.Sprintf("pcapng обрывается на заголовке блока: %v", err))
+		}
+
+		blockType := order.Uint32(head)
+
+		if blockType == pcapngSectionType {
+			// порядок байт секции определяется по ее первому полю
+			bom := make([]byte, 4)
+			if _, err := io.ReadFull(r, bom); err != nil {
+				return errors.New(fmt.Sprintf("pcapng обрывается на заголовке секции: %v", err))
+			}
+
+			if binary.LittleEndian.Uint32(bom) == pcapngByteOrder {
+				order = binary.LittleEndian
+			} else if binary.BigEndian.Uint32(bom) == pcapngByteOrder {
+				order = binary.BigEndian
+			} else {
+				return errors.New("Некорректный порядок байт секции pcapng")
+			}
+
+			interfaces = interfaces[:0]
+
+			length := order.Uint32(head[4:])
+			if length < 16 {
+				return errors.New(fmt.Sprintf("Некорректная длинна блока pcapng [%v]", length))
+			}
+
+			if _, err := io.CopyN(io.Discard, r, int64(length-12)); err != nil {
+				return errors.New(fmt.Sprintf("pcapng обрывается на заголовке секции: %v", err))
+			}
+
+			continue
+		}
+
+		length := order.Uint32(head[4:])
+
+		if length < 12 || length%4 != 0 {
+			return errors.New(fmt.Sprintf("Некорректная длинна блока pcapng [%v]", length))
+		}
+
+		if length > maxBlockLength {
+			return errors.New(fmt.Sprintf("Длинна блока pcapng [%v] больше допустимой [%v]", length, maxBlockLength))
+		}
+
+		body := make([]byte, length-8)
+
+		if _, err := io.ReadFull(r, body); err != nil {
+			return errors.New(fmt.Sprintf("pcapng обрывается на данных блока: %v", err))
+		}
+
+		// в конце блока повторно идет его длинна
+		body = body[:len(body)-4]
+
+		switch blockType {
+		case pcapngInterfaceBlock:
+			if len(body) < 8 {
+				return errors.New("Некорректный блок описания интерфейса pcapng")
+			}
+			interfaces = append(interfaces, interfaceInfo{
+				linkType: uint32(order.Uint16(body)),
+				tsResol:  pcapngTsResol(body[8:], order),
+			})
+		case pcapngEnhancedPacketBlock:
+			if len(body) < 20 {
+				return errors.New("Некорректный блок пакета pcapng")
+			}
+
+			ifaceId := order.Uint32(body)
+			if int(ifaceId) >= len(interfaces) {
+				return errors.New(fmt.Sprintf("Блок пакета pcapng ссылается на неизвестный интерфейс [%v]", ifaceId))
+			}
+
+			iface := interfaces[ifaceId]
+			ts := uint64(order.Uint32(body[4:]))<<32 | uint64(order.Uint32(body[8:]))
+			capLen := int(order.Uint32(body[12:]))
+
+			if 20+capLen > len(body) {
+				return errors.New("Некорректная длинна данных в блоке пакета pcapng")
+			}
+
+			handle(frame{
+				time:     tsToTime(ts, iface.tsResol),
+				linkType: iface.linkType,
+				data:     body[20 : 20+capLen],
+			})
+		case pcapngSimplePacketBlock:
+			if len(body) < 4 || len(interfaces) == 0 {
+				return errors.New("Некорректный простой блок пакета pcapng")
+			}
+
+			capLen := int(order.Uint32(body))
+			if capLen > len(body)-4 {
+				capLen = len(body) - 4
+			}
+
+			// время в простом блоке не указывается
+			handle(frame{
+				linkType: interfaces[0].linkType,
+				data:     body[4 : 4+capLen],
+			})
+		}
+	}
+}
+
+// Получить разрешение меток времени из опций блока описания интерфейса. По умолчанию микросекунды
+func pcapngTsResol(options []byte, order binary.ByteOrder) uint8 {
+	for len(options) >= 4 {
+		code := order.Uint16(options)
+		length := int(order.Uint16(options[2:]))
+
+		if code == pcapngOptionEnd || 4+length > len(options) {
+			break
+		}
+
+		if code == pcapngOptionTsResol && length >= 1 {
+			return options[4]
+		}
+
+		// значения опций выровнены по 4 байта
+		next := 4 + (length+3)/4*4
+		if next > len(options) {
+			break
+		}
+		options = options[next:]
+	}
+
+	return 6
+}
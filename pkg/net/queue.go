@@ -0,0 +1,204 @@
+package net
+
+import (
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Сколько данных может прислать клиент, пока ждет в очереди. Клиент, приславший больше, отключается
+const maxQueuedReceived = 1 << 16
+
+type queuedClient struct {
+	client     *Client
+	enqueuedAt time.Time
+	// закрывается, когда watch перестает читать соединение
+	watchDone chan struct{}
+	// соединение закрыто клиентом, пока он ждал в очереди
+	disconnected bool
+	// данные, которые клиент прислал, пока ждал в очереди. Будут прочитаны после регистрации клиента
+	received []byte
+}
+
+// Соединение, при чтении которого сначала возвращаются уже прочитанные данные
+type bufferedConn struct {
+	net.Conn
+	buf []byte
+}
+
+func (this *bufferedConn) Read(b []byte) (int, error) {
+	if len(this.buf) > 0 {
+		n := copy(b, this.buf)
+		this.buf = this.buf[n:]
+		return n, nil
+	}
+	return this.Conn.Read(b)
+}
+
+// Читать соединение клиента, пока он ждет в очереди, чтобы сразу убрать его из очереди, если он отключится.
+//
+// Завершается при отключении клиента или при остановке через stopWatch.
+func (this *Server) watch(qc *queuedClient) {
+	buf := make([]byte, 512)
+
+	for {
+		n, err := qc.client.conn.Read(buf)
+		qc.received = append(qc.received, buf[:n]...)
+
+		if err == nil && len(qc.received) <= maxQueuedReceived {
+			continue
+		}
+
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			// остановлено через stopWatch
+			close(qc.watchDone)
+			return
+		}
+
+		qc.disconnected = true
+		close(qc.watchDone)
+
+		this.runTask(func() {
+			if this.removeFromQueue(qc) {
+				log.Printf("Клиент %v отключился, ожидая в очереди", qc.client.ip)
+				this.releaseIPSlot(qc.client.ip)
+				qc.client.close()
+				this.notifyQueuePositions()
+			}
+		})
+
+		return
+	}
+}
+
+// Перестать читать соединение клиента перед его регистрацией.
+//
+// Возвращает false, если клиент уже отключился.
+func (this *queuedClient) stopWatch() bool {
+	conn := this.client.conn
+
+	conn.SetReadDeadline(time.Now())
+	<-this.watchDone
+	conn.SetReadDeadline(time.Time{})
+
+	if this.disconnected {
+		return false
+	}
+
+	if len(this.received) > 0 {
+		this.client.conn = &bufferedConn{Conn: conn, buf: this.received}
+	}
+
+	return true
+}
+
+// Поставить клиента в очередь ожидания. Вызывается только в основном цикле сервера.
+//
+// Возвращает false, если очередь отключена или заполнена.
+func (this *Server) enqueue(cl *Client) bool {
+	if len(this.queue) >= int(this.MaxQueueLength) {
+		return false
+	}
+
+	qc := &queuedClient{
+		client:     cl,
+		enqueuedAt: time.Now(),
+		watchDone:  make(chan struct{}),
+	}
+
+	this.queue = append(this.queue, qc)
+	this.updateQueueLength()
+	log.Printf("Клиент %v поставлен в очередь ожидания. Позиция: %v", cl.ip, len(this.queue))
+	this.emitter.Emit("queue", cl, uint16(len(this.queue)))
+
+	go this.watch(qc)
+
+	go func() {
+		time.Sleep(time.Second * time.Duration(this.MaxQueueWaitTimeout))
+		this.sendTask(func() {
+			if this.removeFromQueue(qc) {
+				log.Printf("Превышено время ожидания в очереди [%v]", cl.ip)
+				this.releaseIPSlot(cl.ip)
+				cl.Reject(ERROR_SERVER_IS_FULL)
+				this.notifyQueuePositions()
+			}
+		})
+	}()
+
+	return true
+}
+
+func (this *Server) removeFromQueue(qc *queuedClient) bool {
+	for i, q := range this.queue {
+		if q == qc {
+			this.queue = append(this.queue[:i], this.queue[i+1:]...)
+			this.updateQueueLength()
+			return true
+		}
+	}
+	return false
+}
+
+// Зарегистрировать клиентов из очереди, пока есть свободные места. Вызывается только в основном цикле сервера.
+func (this *Server) processQueue() {
+	if len(this.queue) == 0 {
+		return
+	}
+
+	admitted := false
+
+	for len(this.queue) > 0 && this.clientsCount < this.MaxClientsCount {
+		qc := this.queue[0]
+		this.queue = this.queue[1:]
+		admitted = true
+
+		if !qc.stopWatch() {
+			log.Printf("Клиент %v отключился, ожидая в очереди", qc.client.ip)
+			this.releaseIPSlot(qc.client.ip)
+			qc.client.close()
+			continue
+		}
+
+		// технические работы могли начаться, пока клиент ждал в очереди
+		if allow, errorId := this.admitMaintenance(qc.client.conn.RemoteAddr()); !allow {
+			log.Printf("Клиент %v из очереди ожидания отклонен в связи с техническими работами", qc.client.ip)
+			this.releaseIPSlot(qc.client.ip)
+			qc.client.Reject(errorId)
+			continue
+		}
+
+		log.Printf("Клиент %v дождался своей очереди за %v", qc.client.ip, time.Since(qc.enqueuedAt))
+		this.registerClient(qc.client)
+	}
+
+	if admitted {
+		this.updateQueueLength()
+		this.notifyQueuePositions()
+	}
+}
+
+func (this *Server) notifyQueuePositions() {
+	for i, qc := range this.queue {
+		this.emitter.Emit("queue", qc.client, uint16(i+1))
+	}
+}
+
+// Подписаться на изменение позиции клиентов в очереди ожидания.
+//
+// "cb" - будет вызвана при постановке клиента в очередь и при каждом изменении его позиции (позиции начинаются с 1).
+// Вызывается в основном цикле сервера. Например, можно отправить клиенту пакет с его позицией.
+func (this *Server) OnQueuePositionChanged(cb func(c *Client, position uint16), once bool) {
+	this.emitter.AddEventHandler("queue", func(args ...interface{}) {
+		cb(args[0].(*Client), args[1].(uint16))
+	}, once)
+}
+
+func (this *Server) updateQueueLength() {
+	atomic.StoreUint32(&this.queueLength, uint32(len(this.queue)))
+}
+
+// Получить кол-во клиентов в очереди ожидания
+func (this *Server) GetQueueLength() uint16 {
+	return uint16(atomic.LoadUint32(&this.queueLength))
+}
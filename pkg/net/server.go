@@ -5,16 +5,30 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"sync"
 	"time"
 
+	"github.com/tuxuuman/r2o-core/internal/events"
+	"github.com/tuxuuman/r2o-core/internal/ratelimit"
 	"github.com/tuxuuman/r2o-core/pkg/net/packet"
 )
 
 const (
 	ERROR_SERVER_IS_FULL         uint32 = 1855293908
 	ERROR_IDENTIFICATION_TIMEOUT uint32 = 801713924
+	// Ошибка отправляемая клиенту, если при обработке его пакета что-то пошло не так
+	ERROR_PACKET_HANDLING uint32 = 2547627153
 )
 
+// Функция допуска подключения.
+//
+// Вызывается для каждого нового подключения до того, как клиент будет зарегистрирован и учтен в кол-ве клиентов сервера.
+//
+// "remoteAddr" - адрес подключившегося клиента
+//
+// Если "allow" = false, подключение будет отклонено через Reject с ошибкой "rejectErrorId"
+type AdmitFunc = func(remoteAddr net.Addr) (allow bool, rejectErrorId uint32)
+
 type ClientPacket struct {
 	Client *Client
 	Packet *packet.Packet
@@ -23,13 +37,59 @@ type ClientPacket struct {
 type Server struct {
 	host         string
 	port         uint16
+	listenerMu   sync.Mutex
 	listener     net.Listener
 	clients      map[uint16]*Client
 	clientsCount uint16
+	admitFuncs   []AdmitFunc
+	emitter      events.Emitter
+	onConnection func(c *Client)
+	// задачи выполняемые в основном цикле сервера. только в нем можно работать со списком клиентов и очередью
+	tasks       chan func()
+	queue       []*queuedClient
+	queueLength uint32
+	maintenance *maintenance
+
+	// закрывается при вызове Stop
+	stop     chan struct{}
+	stopOnce sync.Once
+	// закрывается, когда основной цикл сервера завершен
+	stopped chan struct{}
+
+	limitsMu         sync.Mutex
+	ipConnections    map[string]uint16
+	ipBuckets        map[string]*ratelimit.TokenBucket
+	lastBucketsSweep time.Time
+
+	rateLimitCounters *rateLimitCounters
+
 	// Максимальное кол-во клиентов. при достижении лимита которого, все новые подключения будут автоматически оклонятся.
 	MaxClientsCount uint16
 	// Максимальное время ожидания подтверждения подключения клиента (По умолчанию: 10 сек).
 	MaxClientAcceptTimeout uint16
+	// Максимальное кол-во одновременных подключений с одного IP. 0 - без ограничений (По умолчанию: 0).
+	MaxConnectionsPerIP uint16
+	// Допустимое кол-во новых подключений в секунду с одного IP. 0 - без ограничений (По умолчанию: 0).
+	ConnectionRate float64
+	// Сколько подключений с одного IP можно сделать подряд, прежде чем начнет действовать ограничение ConnectionRate (По умолчанию: 5).
+	ConnectionBurst uint16
+	// Id ошибки, с которой отклоняются подключения превысившие ограничения MaxConnectionsPerIP или ConnectionRate (По умолчанию: ERROR_SERVER_IS_FULL).
+	ConnectionLimitErrorId uint32
+	// Максимальная длинна очереди ожидания, в которую попадают новые клиенты, когда сервер заполнен. 0 - очередь отключена (По умолчанию: 0).
+	MaxQueueLength uint16
+	// Максимальное время ожидания клиента в очереди в секундах, после которого он будет отклонен с ERROR_SERVER_IS_FULL (По умолчанию: 300 сек).
+	MaxQueueWaitTimeout uint16
+	// Id ошибки, с которой отклоняются и отключаются клиенты во время технических работ (По умолчанию: ERROR_SERVER_IS_FULL).
+	MaintenanceErrorId uint32
+	// Отклонять во время технических работ подключения с IP не из списка разрешенных сразу, не дожидаясь авторизации.
+	// Аккаунты из AllowMaintenanceAccount с других IP в этом случае не допускаются (По умолчанию: false).
+	MaintenanceRejectByIP bool
+	// Ограничение частоты всех пакетов для каждого нового клиента. Rate = 0 - без ограничений (По умолчанию: без ограничений).
+	ClientRateLimit PacketRateLimit
+	// Ограничения частоты пакетов по их id для каждого нового клиента.
+	PacketRateLimits map[uint16]PacketRateLimit
+	// Необязательная запись трафика всех клиентов, включая отклоненных (По умолчанию: nil - запись отключена).
+	Capture *packet.CaptureWriter
 }
 
 func (this *Server) genNewClientId() uint16 {
@@ -49,11 +109,51 @@ func (this *Server) GetClientsCount() uint16 {
 	return this.clientsCount
 }
 
+// Добавить функцию допуска подключений (бан IP, ограничения, тех. работы и т.д.)
+//
+// Функции вызываются в порядке добавления, до первого отказа. Вызываются из горутины принимающей подключения, поэтому должны быть потоко-безопасными.
+//
+// Добавлять функции нужно до запуска сервера.
+func (this *Server) AddAdmitFunc(admit AdmitFunc) {
+	this.admitFuncs = append(this.admitFuncs, admit)
+}
+
+// Проверить допуск подключения всеми функциями допуска.
+func (this *Server) admit(remoteAddr net.Addr) (bool, uint32) {
+	if allow, errorId := this.admitMaintenance(remoteAddr); !allow {
+		return false, errorId
+	}
+	for _, admit := range this.admitFuncs {
+		if allow, errorId := admit(remoteAddr); !allow {
+			return false, errorId
+		}
+	}
+	return true, 0
+}
+
 // Запущен ли сервер
 func (this *Server) IsStarted() bool {
+	this.listenerMu.Lock()
+	defer this.listenerMu.Unlock()
+
 	return this.listener != nil
 }
 
+// Остановить сервер: перестать принимать подключения и отключить всех клиентов, в том числе ожидающих в очереди.
+//
+// Блокирует выполнение, пока сервер не остановится. После возврата порт свободен. Повторно запустить остановленный сервер нельзя.
+func (this *Server) Stop() {
+	if !this.IsStarted() {
+		return
+	}
+
+	this.stopOnce.Do(func() {
+		close(this.stop)
+	})
+
+	<-this.stopped
+}
+
 // ЗАпустить сервер
 //
 // "onConnection" - коллбэк который будет вызван при подключении клиента
@@ -69,62 +169,137 @@ func (this *Server) Start(onConnection func(c *Client)) {
 		panic(err)
 	}
 
+	this.listenerMu.Lock()
 	this.listener = ln
+	this.listenerMu.Unlock()
+
+	this.onConnection = onConnection
 	log.Printf("Сервер запущен: %v", address)
-	taskChan := make(chan func())
 
 	go func() {
 		for {
 			conn, err := ln.Accept()
 
 			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
 				log.Println("Не удалось обработать подключение клиента", err)
 				continue
 			}
 
 			func() {
-				clId := this.genNewClientId()
-				cl := createClient(clId, conn)
+				// id будет назначен при регистрации клиента
+				cl := createClient(0, conn)
 
-				if this.clientsCount >= this.MaxClientsCount {
-					cl.Reject(ERROR_SERVER_IS_FULL)
-					return
+				if this.Capture != nil {
+					cl.SetCapture(this.Capture)
 				}
 
-				this.clients[clId] = &cl
-				this.clientsCount += 1
+				if allow, errorId := this.admit(conn.RemoteAddr()); !allow {
+					log.Printf("Подключение клиента %v отклонено [%v]", cl.ip, errorId)
+					cl.Reject(errorId)
+					return
+				}
 
-				log.Printf("Подключился новый клиент %v", cl.ip)
+				if limit := this.acquireIPSlot(cl.ip); limit != "" {
+					this.limitExceeded(cl.ip, limit)
+					cl.Reject(this.ConnectionLimitErrorId)
+					return
+				}
 
-				cl.OnDisconnect(func() {
-					taskChan <- func() {
-						delete(this.clients, clId)
-						this.clientsCount -= 1
-					}
-				}, true)
-
-				go func() {
-					time.Sleep(time.Second * time.Duration(this.MaxClientAcceptTimeout))
-					taskChan <- func() {
-						if cl.accepted == false && cl.rejected == false {
-							log.Printf("Превышено время ожидания подтверждения подключения [%v][%v]", clId, cl.ip)
-							cl.Reject(ERROR_IDENTIFICATION_TIMEOUT)
-						}
+				registered := this.sendTask(func() {
+					if this.clientsCount < this.MaxClientsCount && len(this.queue) == 0 {
+						this.registerClient(&cl)
+					} else if !this.enqueue(&cl) {
+						this.releaseIPSlot(cl.ip)
+						cl.Reject(ERROR_SERVER_IS_FULL)
 					}
-				}()
+				})
 
-				taskChan <- func() {
-					onConnection(&cl)
+				if !registered {
+					// сервер остановлен
+					cl.Close()
 				}
 			}()
 		}
 	}()
 
-	for task := range taskChan {
-		task()
+	for {
+		select {
+		case task := <-this.tasks:
+			task()
+		case <-this.stop:
+			this.shutdown(ln)
+			log.Printf("Сервер остановлен: %v", address)
+			return
+		}
+	}
+}
+
+// Закрыть подключения сервера при остановке. Вызывается только в основном цикле сервера.
+func (this *Server) shutdown(ln net.Listener) {
+	ln.Close()
+
+	for _, cl := range this.clients {
+		cl.Close()
+	}
+
+	for _, qc := range this.queue {
+		qc.client.Close()
+	}
+
+	close(this.stopped)
+}
+
+// Передать задачу в основной цикл сервера и дождаться, пока он ее примет. false - сервер остановлен и задача не будет выполнена.
+func (this *Server) sendTask(task func()) bool {
+	select {
+	case this.tasks <- task:
+		return true
+	case <-this.stop:
+		return false
 	}
 }
 
+// Выполнить задачу в основном цикле сервера, не дожидаясь ее выполнения.
+func (this *Server) runTask(task func()) {
+	go this.sendTask(task)
+}
+
+// Зарегистрировать клиента на сервере и передать его в onConnection. Вызывается только в основном цикле сервера.
+func (this *Server) registerClient(cl *Client) {
+	clId := this.genNewClientId()
+	cl.id = clId
+
+	this.applyRateLimits(cl)
+	this.clients[clId] = cl
+	this.clientsCount += 1
+
+	log.Printf("Подключился новый клиент %v", cl.ip)
+
+	cl.OnDisconnect(func() {
+		this.runTask(func() {
+			delete(this.clients, clId)
+			this.clientsCount -= 1
+			this.releaseIPSlot(cl.ip)
+			this.processQueue()
+		})
+	}, true)
+
+	go func() {
+		time.Sleep(time.Second * time.Duration(this.MaxClientAcceptTimeout))
+		this.sendTask(func() {
+			if cl.accepted == false && cl.rejected == false {
+				log.Printf("Превышено время ожидания подтверждения подключения [%v][%v]", clId, cl.ip)
+				cl.Reject(ERROR_IDENTIFICATION_TIMEOUT)
+			}
+		})
+	}()
+
+	this.onConnection(cl)
+}
+
 func CreateServer(host string, port uint16) Server {
 	return Server{
 		host:                   host,
@@ -132,5 +307,18 @@ func CreateServer(host string, port uint16) Server {
 		clients:                make(map[uint16]*Client, 1024),
 		MaxClientsCount:        1000,
 		MaxClientAcceptTimeout: 10,
+		MaxQueueWaitTimeout:    300,
+		tasks:                  make(chan func()),
+		stop:                   make(chan struct{}),
+		stopped:                make(chan struct{}),
+		maintenance:            createMaintenance(),
+		MaintenanceErrorId:     ERROR_SERVER_IS_FULL,
+		ConnectionBurst:        5,
+		ConnectionLimitErrorId: ERROR_SERVER_IS_FULL,
+		emitter:                events.CreateEmitter(),
+		ipConnections:          make(map[string]uint16),
+		ipBuckets:              make(map[string]*ratelimit.TokenBucket),
+		PacketRateLimits:       make(map[uint16]PacketRateLimit),
+		rateLimitCounters:      &rateLimitCounters{},
 	}
 }
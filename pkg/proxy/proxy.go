@@ -0,0 +1,256 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	gonet "net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+// Прокси между клиентом игры и сервером для исследования протокола.
+//
+// Принимает подключения клиента, для каждого из них подключается к серверу и пересылает пакеты в обе стороны.
+// Перед пересылкой пакеты расшифровываются и передаются в обработчики (Hook), которые могут их изучить, изменить или отбросить.
+// Пакеты отправляются получателю в том же виде (зашифрованными или нет), в котором были получены.
+
+// Обработчик пакета, проходящего через прокси.
+//
+// "direction" - DIRECTION_CLIENT_TO_SERVER для пакетов клиента и DIRECTION_SERVER_TO_CLIENT для пакетов сервера
+//
+// "p" - расшифрованный пакет
+//
+// Возвращает пакет, который нужно переслать дальше: "p", новый пакет вместо него или nil, чтобы отбросить пакет.
+// Если полученный пакет был зашифрован, то возвращенный пакет будет зашифрован перед отправкой.
+type Hook func(s *Session, direction packet.Direction, p *packet.Packet) *packet.Packet
+
+// Соединение клиента через прокси
+type Session struct {
+	proxy    *Proxy
+	id       uint32
+	client   gonet.Conn
+	upstream gonet.Conn
+	// запись в каждое соединение выполняется под своей блокировкой, чтобы пересылка и внедрение пакетов не перемешались
+	clientMu   sync.Mutex
+	upstreamMu sync.Mutex
+	closeOnce  sync.Once
+}
+
+// Id соединения. Совпадает с ConnId в записи трафика, если она включена
+func (this *Session) Id() uint32 {
+	return this.id
+}
+
+// Адрес клиента
+func (this *Session) ClientAddr() gonet.Addr {
+	return this.client.RemoteAddr()
+}
+
+// Отправить пакет клиенту от имени сервера. Пакет отправляется в том виде, в котором передан (зашифрованным или нет)
+func (this *Session) SendToClient(p *packet.Packet) error {
+	return this.send(packet.DIRECTION_SERVER_TO_CLIENT, p)
+}
+
+// Отправить пакет серверу от имени клиента. Пакет отправляется в том виде, в котором передан (зашифрованным или нет)
+func (this *Session) SendToServer(p *packet.Packet) error {
+	return this.send(packet.DIRECTION_CLIENT_TO_SERVER, p)
+}
+
+// Закрыть соединения с клиентом и сервером
+func (this *Session) Close() {
+	this.closeOnce.Do(func() {
+		this.client.Close()
+		this.upstream.Close()
+		log.Printf("Прокси: соединение [%v] клиента %v закрыто", this.id, this.ClientAddr())
+	})
+}
+
+func (this *Session) send(direction packet.Direction, p *packet.Packet) error {
+	conn, mu := this.upstream, &this.upstreamMu
+	if direction == packet.DIRECTION_SERVER_TO_CLIENT {
+		conn, mu = this.client, &this.clientMu
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if this.proxy.Capture != nil {
+		if err := this.proxy.Capture.WritePacket(direction, this.id, p); err != nil {
+			log.Printf("Прокси: не удалось записать пакет [%v] в запись трафика: %v", packet.DefaultRegistry.Name(p.Id), err)
+		}
+	}
+
+	_, err := conn.Write(p.Bytes())
+
+	return err
+}
+
+// Вызвать обработчики пакета. Паника в обработчике выводится в лог, а пакет пересылается без изменений
+func (this *Session) runHooks(direction packet.Direction, p *packet.Packet) (result *packet.Packet) {
+	result = p
+
+	for _, hook := range this.proxy.hooks {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Прокси: ошибка в обработчике пакета [%v]: %s", packet.DefaultRegistry.Name(p.Id), r)
+				}
+			}()
+			result = hook(this, direction, result)
+		}()
+
+		if result == nil {
+			return nil
+		}
+	}
+
+	return result
+}
+
+// Пересылать пакеты из "src" получателю в направлении "direction", пока одно из соединений не закроется
+func (this *Session) pipe(src gonet.Conn, direction packet.Direction) {
+	defer this.Close()
+
+	for {
+		p, err := packet.ReadPacket(src)
+
+		if err != nil {
+			return
+		}
+
+		encrypted := p.IsEncrypted()
+		p.Decrypt()
+
+		if this.proxy.LogPackets {
+			log.Print(fmt.Sprintf("\n\nПрокси [%v] %v\n\n", this.id, direction), p.String(), "\n\n")
+		}
+
+		out := this.runHooks(direction, p)
+
+		if out == nil {
+			log.Printf("Прокси [%v] %v: пакет [%v] отброшен", this.id, direction, packet.DefaultRegistry.Name(p.Id))
+			continue
+		}
+
+		if encrypted {
+			out.Encrypt()
+		}
+
+		if err := this.send(direction, out); err != nil {
+			return
+		}
+	}
+}
+
+// Прокси между клиентом игры и сервером (см. CreateProxy)
+type Proxy struct {
+	listenAddr   string
+	upstreamAddr string
+	listener     gonet.Listener
+	hooks        []Hook
+	lastId       uint32
+	mu           sync.Mutex
+	// Выводить все пакеты в лог в расшифрованном виде (По умолчанию: true)
+	LogPackets bool
+	// Необязательная запись трафика. Записываются пакеты в том виде, в котором они отправлены получателю, включая внедренные (По умолчанию: nil)
+	Capture *packet.CaptureWriter
+	// Максимальное время подключения к серверу (По умолчанию: 10 сек)
+	DialTimeout time.Duration
+	// Необязательный коллбэк вызываемый для каждого нового соединения, до начала пересылки пакетов
+	OnSession func(s *Session)
+}
+
+// Добавить обработчик пакетов. Обработчики вызываются в порядке добавления. Добавлять нужно до вызова Listen
+func (this *Proxy) AddHook(hook Hook) {
+	this.hooks = append(this.hooks, hook)
+}
+
+// Начать принимать подключения клиентов. Блокирует выполнение до закрытия (Close)
+func (this *Proxy) Listen() error {
+	ln, err := gonet.Listen("tcp", this.listenAddr)
+
+	if err != nil {
+		return err
+	}
+
+	this.mu.Lock()
+	this.listener = ln
+	this.mu.Unlock()
+
+	log.Printf("Прокси запущен: %v -> %v", this.listenAddr, this.upstreamAddr)
+
+	for {
+		conn, err := ln.Accept()
+
+		if err != nil {
+			if errors.Is(err, gonet.ErrClosed) {
+				return nil
+			}
+			log.Println("Прокси: не удалось принять подключение клиента", err)
+			continue
+		}
+
+		go this.handleConn(conn)
+	}
+}
+
+// Прекратить принимать подключения клиентов. Уже установленные соединения не закрываются
+func (this *Proxy) Close() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if this.listener == nil {
+		return nil
+	}
+
+	return this.listener.Close()
+}
+
+func (this *Proxy) handleConn(conn gonet.Conn) {
+	upstream, err := gonet.DialTimeout("tcp", this.upstreamAddr, this.DialTimeout)
+
+	if err != nil {
+		log.Printf("Прокси: не удалось подключиться к серверу %v для клиента %v: %v", this.upstreamAddr, conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+
+	s := &Session{
+		proxy:    this,
+		client:   conn,
+		upstream: upstream,
+	}
+
+	if this.Capture != nil {
+		s.id = this.Capture.NewConnId()
+	} else {
+		s.id = atomic.AddUint32(&this.lastId, 1)
+	}
+
+	log.Printf("Прокси: новое соединение [%v] клиента %v", s.id, conn.RemoteAddr())
+
+	if this.OnSession != nil {
+		this.OnSession(s)
+	}
+
+	go s.pipe(upstream, packet.DIRECTION_SERVER_TO_CLIENT)
+	s.pipe(conn, packet.DIRECTION_CLIENT_TO_SERVER)
+}
+
+// Создает прокси
+//
+// "listenAddr" - адрес, на котором прокси принимает подключения клиентов, например "127.0.0.1:11004"
+//
+// "upstreamAddr" - адрес сервера, к которому прокси подключается для каждого клиента
+func CreateProxy(listenAddr string, upstreamAddr string) *Proxy {
+	return &Proxy{
+		listenAddr:   listenAddr,
+		upstreamAddr: upstreamAddr,
+		LogPackets:   true,
+		DialTimeout:  time.Second * 10,
+	}
+}
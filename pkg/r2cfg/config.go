@@ -0,0 +1,194 @@
+package r2cfg
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	// Ip логин-сервера
+	KEY_CHANNEL_SERVER_IP = "channelserverip"
+	// Порт логин-сервера
+	KEY_CHANNEL_SERVER_PORT = "channelserverport"
+)
+
+// Строка файла конфигурации
+type line struct {
+	// исходная строка без перевода строки
+	raw string
+	// ключ параметра. пусто для комментариев, пустых и прочих строк
+	key string
+	// все что до значения, включая ключ и "="
+	prefix string
+	value  string
+	// пробелы после значения
+	suffix string
+}
+
+func isComment(str string) bool {
+	return strings.HasPrefix(str, ";") || strings.HasPrefix(str, "#") || strings.HasPrefix(str, "//")
+}
+
+func parseLine(raw string) line {
+	l := line{raw: raw}
+	trimmed := strings.TrimSpace(raw)
+
+	if trimmed == "" || isComment(trimmed) {
+		return l
+	}
+
+	eq := strings.Index(raw, "=")
+
+	if eq < 0 {
+		return l
+	}
+
+	key := strings.TrimSpace(raw[:eq])
+
+	if key == "" {
+		return l
+	}
+
+	rest := raw[eq+1:]
+	valueStart := len(rest) - len(strings.TrimLeft(rest, " \t"))
+	value := strings.TrimRight(rest[valueStart:], " \t")
+
+	l.key = key
+	l.prefix = raw[:eq+1+valueStart]
+	l.value = value
+	l.suffix = rest[valueStart+len(value):]
+
+	return l
+}
+
+// Файл конфигурации клиента игры (R2.cfg).
+//
+// Строки вида "ключ = значение". Комментарии, пустые строки, порядок параметров и форматирование сохраняются при записи.
+// Ключи регистронезависимые.
+type Config struct {
+	lines []line
+	// использовать ли "\r\n" в качестве перевода строки
+	crlf bool
+	// заканчивается ли файл переводом строки
+	trailingNewline bool
+}
+
+func (this *Config) find(key string) int {
+	for i, l := range this.lines {
+		if l.key != "" && strings.EqualFold(l.key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Получить значение параметра
+func (this *Config) Get(key string) (string, bool) {
+	if i := this.find(key); i >= 0 {
+		return this.lines[i].value, true
+	}
+	return "", false
+}
+
+// Установить значение параметра. Если параметра нет, то он будет добавлен в конец файла
+func (this *Config) Set(key string, value string) {
+	if i := this.find(key); i >= 0 {
+		l := &this.lines[i]
+		l.value = value
+		l.raw = l.prefix + l.value + l.suffix
+		return
+	}
+
+	this.lines = append(this.lines, parseLine(key+" = "+value))
+}
+
+// Удалить параметр. Возвращает false если параметра не было
+func (this *Config) Delete(key string) bool {
+	if i := this.find(key); i >= 0 {
+		this.lines = append(this.lines[:i], this.lines[i+1:]...)
+		return true
+	}
+	return false
+}
+
+// Получить список ключей в порядке их следования в файле
+func (this *Config) Keys() []string {
+	keys := []string{}
+	for _, l := range this.lines {
+		if l.key != "" {
+			keys = append(keys, l.key)
+		}
+	}
+	return keys
+}
+
+// Указать адрес логин-сервера, к которому будет подключаться клиент
+func (this *Config) SetLoginServer(ip string, port uint16) {
+	this.Set(KEY_CHANNEL_SERVER_IP, ip)
+	this.Set(KEY_CHANNEL_SERVER_PORT, fmt.Sprint(port))
+}
+
+// Представить конфигурацию в виде среза байт
+func (this *Config) Bytes() []byte {
+	newline := "\n"
+	if this.crlf {
+		newline = "\r\n"
+	}
+
+	buf := new(bytes.Buffer)
+
+	for i, l := range this.lines {
+		buf.WriteString(l.raw)
+		if i < len(this.lines)-1 || this.trailingNewline {
+			buf.WriteString(newline)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// Сохранить конфигурацию в файл
+func (this *Config) Save(path string) error {
+	mode := os.FileMode(0644)
+
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode()
+	}
+
+	return ioutil.WriteFile(path, this.Bytes(), mode)
+}
+
+// Разобрать содержимое файла конфигурации
+func Parse(data []byte) *Config {
+	str := string(data)
+	config := &Config{
+		crlf:            strings.Contains(str, "\r\n"),
+		trailingNewline: len(str) == 0 || strings.HasSuffix(str, "\n"),
+	}
+
+	str = strings.TrimSuffix(strings.ReplaceAll(str, "\r\n", "\n"), "\n")
+
+	if str == "" {
+		return config
+	}
+
+	for _, raw := range strings.Split(str, "\n") {
+		config.lines = append(config.lines, parseLine(raw))
+	}
+
+	return config
+}
+
+// Загрузить файл конфигурации
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data), nil
+}
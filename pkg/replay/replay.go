@@ -0,0 +1,295 @@
+package replay
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/net"
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+// Воспроизведение записанной сессии клиента на работающем сервере.
+//
+// Пакеты клиента (C->S) отправляются серверу в записанном порядке, а полученные ответы сравниваются с пакетами сервера (S->C) из записи.
+
+// Настройки воспроизведения
+type Options struct {
+	// Id соединения из записи, которое нужно воспроизвести. 0 - первое соединение в записи (По умолчанию: 0).
+	ConnId uint32
+	// Множитель задержек между пакетами: 1 - как в записи, 0.5 - в 2 раза быстрее, 0 - без задержек (По умолчанию: 1).
+	TimeScale float64
+	// Перед отправкой очередного пакета ждать, пока сервер отправит столько же пакетов, сколько было в записи до него (По умолчанию: true).
+	WaitResponses bool
+	// Максимальное время ожидания ответов сервера (По умолчанию: 5 сек).
+	ResponseTimeout time.Duration
+}
+
+// Настройки по умолчанию
+func DefaultOptions() Options {
+	return Options{
+		TimeScale:       1,
+		WaitResponses:   true,
+		ResponseTimeout: time.Second * 5,
+	}
+}
+
+// Сравнение ответа сервера с записью
+type Response struct {
+	// Пакет из записи. nil - сервер отправил лишний пакет
+	Expected *packet.Packet
+	// Полученный пакет. nil - сервер не отправил пакет
+	Actual *packet.Packet
+	// Отличия полученного пакета от записанного. Пустой, если пакеты совпадают
+	Diff []string
+}
+
+// Результат воспроизведения
+type Report struct {
+	// Id воспроизведенного соединения
+	ConnId uint32
+	// Кол-во отправленных пакетов
+	Sent int
+	// Ответы сервера в порядке получения
+	Responses []Response
+	// Время, которые заняло воспроизведение
+	Duration time.Duration
+}
+
+// Совпали ли все ответы сервера с записью
+func (this *Report) OK() bool {
+	for _, r := range this.Responses {
+		if len(r.Diff) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Отчет в текстовом виде
+func (this *Report) String() string {
+	result := fmt.Sprintf("Соединение [%v]: отправлено пакетов %v, получено ответов %v за %v\n", this.ConnId, this.Sent, this.actualCount(), this.Duration)
+	diffs := 0
+
+	for i, r := range this.Responses {
+		if len(r.Diff) == 0 {
+			continue
+		}
+
+		diffs += 1
+		result += fmt.Sprintf("\nОтвет #%v:\n", i+1)
+
+		for _, d := range r.Diff {
+			result += "  " + d + "\n"
+		}
+	}
+
+	if diffs == 0 {
+		result += "Все ответы совпадают с записью\n"
+	} else {
+		result += fmt.Sprintf("\nОтличается ответов: %v\n", diffs)
+	}
+
+	return result
+}
+
+func (this *Report) actualCount() int {
+	count := 0
+	for _, r := range this.Responses {
+		if r.Actual != nil {
+			count += 1
+		}
+	}
+	return count
+}
+
+// Сравнить полученный пакет с записанным
+func comparePackets(expected *packet.Packet, actual *packet.Packet) []string {
+	if expected != nil && actual != nil && expected.Id != actual.Id {
+		// поля пакетов разных типов сравнивать бессмысленно
+		return []string{fmt.Sprintf("Ожидался пакет %v, получен %v", packet.DefaultRegistry.Name(expected.Id), packet.DefaultRegistry.Name(actual.Id))}
+	}
+	if diff := packet.Diff(expected, actual); !diff.Equal() {
+		return diff.Summary()
+	}
+	return nil
+}
+
+// Пакеты, полученные от сервера
+type inbox struct {
+	mu      sync.Mutex
+	packets []*packet.Packet
+	closed  bool
+	notify  chan struct{}
+}
+
+func (this *inbox) add(p *packet.Packet) {
+	this.mu.Lock()
+	this.packets = append(this.packets, p)
+	this.mu.Unlock()
+	this.wake()
+}
+
+func (this *inbox) close() {
+	this.mu.Lock()
+	this.closed = true
+	this.mu.Unlock()
+	this.wake()
+}
+
+func (this *inbox) wake() {
+	select {
+	case this.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Ждать, пока не будет получено "count" пакетов, соединение не закроется или не истечет "timeout"
+func (this *inbox) wait(count int, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+
+	for {
+		this.mu.Lock()
+		received := len(this.packets)
+		closed := this.closed
+		this.mu.Unlock()
+
+		if received >= count {
+			return true
+		}
+
+		if closed {
+			return false
+		}
+
+		select {
+		case <-this.notify:
+		case <-deadline:
+			return false
+		}
+	}
+}
+
+func (this *inbox) list() []*packet.Packet {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return append([]*packet.Packet{}, this.packets...)
+}
+
+// Выбрать записи одного соединения
+func connectionRecords(records []packet.Record, connId uint32) (uint32, []packet.Record) {
+	result := []packet.Record{}
+
+	for _, rec := range records {
+		if connId == 0 {
+			connId = rec.ConnId
+		}
+		if rec.ConnId == connId {
+			result = append(result, rec)
+		}
+	}
+
+	return connId, result
+}
+
+// Воспроизвести записанную сессию на сервере "addr" (например "127.0.0.1:11004")
+//
+// "records" - записи сессии (см. packet.ReadCaptureFile)
+//
+// "options" - настройки воспроизведения (см. DefaultOptions)
+func Run(addr string, records []packet.Record, options Options) (*Report, error) {
+	connId, records := connectionRecords(records, options.ConnId)
+
+	if len(records) == 0 {
+		return nil, errors.New(fmt.Sprintf("В записи нет пакетов соединения [%v]", options.ConnId))
+	}
+
+	report := Report{ConnId: connId}
+	received := &inbox{notify: make(chan struct{}, 1)}
+	connected := make(chan *net.Client, 1)
+	failed := make(chan error, 1)
+
+	go net.Connect(addr, func(c net.Client) {
+		c.OnPacket(func(p *packet.Packet) {
+			received.add(p)
+		}, false)
+		c.OnDisconnect(received.close, true)
+		connected <- &c
+	}, func(err error) {
+		failed <- err
+	})
+
+	var client *net.Client
+
+	select {
+	case client = <-connected:
+	case err := <-failed:
+		return nil, err
+	}
+
+	defer client.Close()
+
+	started := time.Now()
+	firstTime := records[0].Time
+	// кол-во пакетов сервера в записи до текущего пакета
+	expectedCount := 0
+	expected := []*packet.Packet{}
+
+	for _, rec := range records {
+		p, err := rec.Packet()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if rec.Direction == packet.DIRECTION_SERVER_TO_CLIENT {
+			p.Decrypt()
+			expected = append(expected, p)
+			expectedCount += 1
+			continue
+		}
+
+		if options.WaitResponses {
+			received.wait(expectedCount, options.ResponseTimeout)
+		}
+
+		if options.TimeScale > 0 {
+			at := started.Add(time.Duration(float64(rec.Time.Sub(firstTime)) * options.TimeScale))
+			time.Sleep(time.Until(at))
+		}
+
+		client.SendPacket(p)
+		report.Sent += 1
+	}
+
+	received.wait(expectedCount, options.ResponseTimeout)
+	report.Duration = time.Since(started)
+
+	actual := received.list()
+
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		r := Response{}
+		if i < len(expected) {
+			r.Expected = expected[i]
+		}
+		if i < len(actual) {
+			r.Actual = actual[i]
+		}
+		r.Diff = comparePackets(r.Expected, r.Actual)
+		report.Responses = append(report.Responses, r)
+	}
+
+	return &report, nil
+}
+
+// Воспроизвести сессию из файла записи трафика .r2pac (см. Run)
+func RunFile(addr string, path string, options Options) (*Report, error) {
+	records, err := packet.ReadCaptureFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Run(addr, records, options)
+}
@@ -0,0 +1,367 @@
+package security
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Ошибка "Заблокированный IP." (eErrNoIpBlocked)
+	ERROR_IP_BLOCKED uint32 = 2208232205
+)
+
+// Запись бан-листа
+type Ban struct {
+	// IP адрес или подсеть в CIDR нотации (127.0.0.1, 10.0.0.0/8)
+	Target string `json:"target"`
+	// Причина блокировки
+	Reason string `json:"reason,omitempty"`
+	// Заметка администратора
+	Note string `json:"note,omitempty"`
+	// Время блокировки
+	CreatedAt time.Time `json:"createdAt"`
+	// Время окончания блокировки. Нулевое значение - бессрочная блокировка
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+
+	network *net.IPNet
+}
+
+// Истек ли срок блокировки
+func (this *Ban) IsExpired() bool {
+	return !this.ExpiresAt.IsZero() && time.Now().After(this.ExpiresAt)
+}
+
+// Потоко-безопасный список заблокированных IP адресов и подсетей, хранящийся в локальном json файле.
+type BanList struct {
+	path    string
+	bans    map[string]*Ban
+	modTime time.Time
+	mu      sync.RWMutex
+	// Id ошибки, с которой будут отклоняться подключения с заблокированных IP (По умолчанию: ERROR_IP_BLOCKED)
+	RejectErrorId uint32
+}
+
+// Привести IP или подсеть к каноническому виду подсети
+func parseTarget(target string) (*net.IPNet, error) {
+	target = strings.TrimSpace(target)
+
+	if strings.Contains(target, "/") {
+		_, network, err := net.ParseCIDR(target)
+		return network, err
+	}
+
+	ip := net.ParseIP(target)
+
+	if ip == nil {
+		return nil, errors.New(fmt.Sprintf("Не удалось распознать IP адрес [%v]", target))
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// Получить IP из адреса подключения
+func AddrIP(addr net.Addr) net.IP {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+
+	if err != nil {
+		host = addr.String()
+	}
+
+	return net.ParseIP(host)
+}
+
+// Заблокировать IP или подсеть. Изменения сразу сохраняются в файл.
+//
+// "target" - IP адрес или подсеть в CIDR нотации
+//
+// "duration" - длительность блокировки. 0 - бессрочно
+//
+// "reason" - причина блокировки
+//
+// "note" - заметка администратора
+func (this *BanList) Ban(target string, duration time.Duration, reason string, note string) error {
+	network, err := parseTarget(target)
+
+	if err != nil {
+		return err
+	}
+
+	ban := &Ban{
+		Target:    network.String(),
+		Reason:    reason,
+		Note:      note,
+		CreatedAt: time.Now(),
+		network:   network,
+	}
+
+	if duration > 0 {
+		ban.ExpiresAt = ban.CreatedAt.Add(duration)
+	}
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.bans[ban.Target] = ban
+
+	return this.save()
+}
+
+// Разблокировать IP или подсеть. Изменения сразу сохраняются в файл.
+//
+// Возвращает false, если такой записи в бан-листе не было.
+func (this *BanList) Unban(target string) (bool, error) {
+	network, err := parseTarget(target)
+
+	if err != nil {
+		return false, err
+	}
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if _, exists := this.bans[network.String()]; !exists {
+		return false, nil
+	}
+
+	delete(this.bans, network.String())
+
+	return true, this.save()
+}
+
+// Найти действующую блокировку, под которую попадает IP
+func (this *BanList) Find(ip net.IP) (Ban, bool) {
+	if ip == nil {
+		return Ban{}, false
+	}
+
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	for _, ban := range this.bans {
+		if !ban.IsExpired() && ban.network.Contains(ip) {
+			return *ban, true
+		}
+	}
+
+	return Ban{}, false
+}
+
+// Заблокирован ли IP
+func (this *BanList) IsBanned(ip net.IP) bool {
+	_, banned := this.Find(ip)
+	return banned
+}
+
+// Получить список всех блокировок, включая истекшие, отсортированный по времени блокировки
+func (this *BanList) List() []Ban {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	result := make([]Ban, 0, len(this.bans))
+
+	for _, ban := range this.bans {
+		result = append(result, *ban)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result
+}
+
+// Удалить истекшие блокировки. Изменения сразу сохраняются в файл.
+func (this *BanList) Prune() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	for target, ban := range this.bans {
+		if ban.IsExpired() {
+			delete(this.bans, target)
+		}
+	}
+
+	return this.save()
+}
+
+// Функция допуска подключения для Server.AddAdmitFunc. Отклоняет подключения с заблокированных IP.
+func (this *BanList) Admit(remoteAddr net.Addr) (bool, uint32) {
+	if ban, banned := this.Find(AddrIP(remoteAddr)); banned {
+		log.Printf("Подключение с заблокированного IP %v [%v] %v", remoteAddr, ban.Target, ban.Reason)
+		return false, this.RejectErrorId
+	}
+	return true, 0
+}
+
+// Перечитать бан-лист из файла
+func (this *BanList) Reload() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	return this.load()
+}
+
+// Следить за изменениями файла бан-листа и перечитывать его при изменении.
+//
+// "interval" - интервал проверки файла
+//
+// Возвращает функцию, которая останавливает слежение.
+func (this *BanList) Watch(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				info, err := os.Stat(this.path)
+				if err != nil {
+					continue
+				}
+
+				this.mu.RLock()
+				changed := !info.ModTime().Equal(this.modTime)
+				this.mu.RUnlock()
+
+				if changed {
+					if err := this.Reload(); err != nil {
+						log.Printf("Не удалось перечитать бан-лист [%v]: %v", this.path, err)
+					} else {
+						log.Printf("Бан-лист [%v] перечитан", this.path)
+					}
+				}
+			}
+		}
+	}()
+
+	once := sync.Once{}
+
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+}
+
+func (this *BanList) load() error {
+	data, err := ioutil.ReadFile(this.path)
+
+	if os.IsNotExist(err) {
+		this.bans = make(map[string]*Ban)
+		this.modTime = time.Time{}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	list := []*Ban{}
+
+	if len(strings.TrimSpace(string(data))) > 0 {
+		if err := json.Unmarshal(data, &list); err != nil {
+			return err
+		}
+	}
+
+	bans := make(map[string]*Ban, len(list))
+
+	for _, ban := range list {
+		network, err := parseTarget(ban.Target)
+		if err != nil {
+			return err
+		}
+		ban.network = network
+		ban.Target = network.String()
+		bans[ban.Target] = ban
+	}
+
+	if info, err := os.Stat(this.path); err == nil {
+		this.modTime = info.ModTime()
+	}
+
+	this.bans = bans
+
+	return nil
+}
+
+func (this *BanList) save() error {
+	list := make([]*Ban, 0, len(this.bans))
+
+	for _, ban := range this.bans {
+		list = append(list, ban)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+
+	data, err := json.MarshalIndent(list, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	// пишем во временный файл и переименовываем, чтобы не оставить файл недописанным
+	tmp, err := ioutil.TempFile(filepath.Dir(this.path), filepath.Base(this.path)+".*.tmp")
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), this.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if info, err := os.Stat(this.path); err == nil {
+		this.modTime = info.ModTime()
+	}
+
+	return nil
+}
+
+// Создает бан-лист, хранящийся в файле "path", и загружает его. Если файла нет, бан-лист будет пустым, а файл создан при первом изменении.
+func CreateBanList(path string) (*BanList, error) {
+	banList := &BanList{
+		path:          path,
+		bans:          make(map[string]*Ban),
+		RejectErrorId: ERROR_IP_BLOCKED,
+	}
+
+	if err := banList.load(); err != nil {
+		return nil, err
+	}
+
+	return banList, nil
+}
@@ -0,0 +1,170 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Хранилище сессий в локальном json файле.
+//
+// Подходит для передачи сессий между логин-сервером и игровыми серверами, работающими в разных процессах на одной машине.
+// Файл читается и записывается при каждой операции под файловой блокировкой (<path>.lock).
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+	// Максимальное время ожидания файловой блокировки (По умолчанию: 5 сек)
+	LockTimeout time.Duration
+	// Время, после которого блокировка считается брошенной упавшим процессом и удаляется (По умолчанию: 30 сек)
+	StaleLockTimeout time.Duration
+}
+
+func (this *FileStore) lock() (func(), error) {
+	lockPath := this.path + ".lock"
+	deadline := time.Now().Add(this.LockTimeout)
+
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+
+		if err == nil {
+			f.Close()
+			return func() {
+				os.Remove(lockPath)
+			}, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > this.StaleLockTimeout {
+			os.Remove(lockPath)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, errors.New(fmt.Sprintf("Не удалось получить блокировку файла сессий [%v]", lockPath))
+		}
+
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func (this *FileStore) load() (sessions, error) {
+	data, err := ioutil.ReadFile(this.path)
+	result := make(sessions)
+
+	if os.IsNotExist(err) {
+		return result, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return result, nil
+	}
+
+	list := []*Session{}
+
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	for _, s := range list {
+		result[s.Id] = s
+	}
+
+	return result, nil
+}
+
+func (this *FileStore) save(ss sessions) error {
+	ss.prune()
+	list := make([]*Session, 0, len(ss))
+
+	for _, s := range ss {
+		list = append(list, s)
+	}
+
+	data, err := json.Marshal(list)
+
+	if err != nil {
+		return err
+	}
+
+	tmpPath := this.path + ".tmp"
+
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, filepath.Clean(this.path))
+}
+
+// Выполнить операцию над сессиями из файла под блокировкой и сохранить их обратно в файл
+func (this *FileStore) update(op func(ss sessions) error) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	unlock, err := this.lock()
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	ss, err := this.load()
+
+	if err != nil {
+		return err
+	}
+
+	opErr := op(ss)
+
+	if err := this.save(ss); err != nil {
+		return err
+	}
+
+	return opErr
+}
+
+func (this *FileStore) Issue(accountId uint32, ip string, ttl time.Duration) (Session, error) {
+	var result Session
+	err := this.update(func(ss sessions) error {
+		var err error
+		result, err = ss.issue(accountId, ip, ttl)
+		return err
+	})
+	return result, err
+}
+
+func (this *FileStore) Bind(sessionId uint32, serverId uint16) error {
+	return this.update(func(ss sessions) error {
+		return ss.bind(sessionId, serverId)
+	})
+}
+
+func (this *FileStore) Consume(sessionId uint32, ip string, serverId uint16) (Session, error) {
+	var result Session
+	err := this.update(func(ss sessions) error {
+		var err error
+		result, err = ss.consume(sessionId, ip, serverId)
+		return err
+	})
+	return result, err
+}
+
+// Создает хранилище сессий в файле "path"
+func CreateFileStore(path string) *FileStore {
+	return &FileStore{
+		path:             path,
+		LockTimeout:      time.Second * 5,
+		StaleLockTimeout: time.Second * 30,
+	}
+}
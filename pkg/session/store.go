@@ -0,0 +1,185 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound    = errors.New("Сессия не найдена или уже использована")
+	ErrExpired     = errors.New("Срок действия сессии истек")
+	ErrWrongIP     = errors.New("Сессия выдана для другого IP")
+	ErrWrongServer = errors.New("Сессия выдана для другого игрового сервера")
+	ErrNotBound    = errors.New("Игровой сервер для сессии не выбран")
+)
+
+// Сессия игрока, выданная логин-сервером.
+//
+// Клиент получает id сессии в пакете 3101 и передает его игровому серверу при подключении.
+type Session struct {
+	// Id сессии
+	Id uint32 `json:"id"`
+	// Id аккаунта
+	AccountId uint32 `json:"accountId"`
+	// IP клиента, которому выдана сессия
+	IP string `json:"ip"`
+	// Id игрового сервера, к которому разрешено подключение. 0 - сервер еще не выбран
+	ServerId uint16 `json:"serverId,omitempty"`
+	// Время выдачи
+	IssuedAt time.Time `json:"issuedAt"`
+	// Время окончания действия
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Истек ли срок действия сессии
+func (this *Session) IsExpired() bool {
+	return time.Now().After(this.ExpiresAt)
+}
+
+// Хранилище сессий для передачи игрока от логин-сервера игровому
+type Store interface {
+	// Выдать новую сессию аккаунту
+	//
+	// "ip" - IP клиента
+	//
+	// "ttl" - время действия сессии
+	Issue(accountId uint32, ip string, ttl time.Duration) (Session, error)
+	// Привязать сессию к игровому серверу, который выбрал игрок (пакет 3120)
+	Bind(sessionId uint32, serverId uint16) error
+	// Проверить и использовать сессию при подключении к игровому серверу. Повторно использовать сессию нельзя.
+	//
+	// "ip" - IP клиента
+	//
+	// "serverId" - id игрового сервера, к которому подключается клиент
+	Consume(sessionId uint32, ip string, serverId uint16) (Session, error)
+}
+
+func generateId(exists func(id uint32) bool) (uint32, error) {
+	b := make([]byte, 4)
+
+	for {
+		if _, err := rand.Read(b); err != nil {
+			return 0, err
+		}
+		if id := binary.LittleEndian.Uint32(b); id != 0 && !exists(id) {
+			return id, nil
+		}
+	}
+}
+
+// Операции над набором сессий, общие для всех хранилищ. Потоко-безопасность обеспечивает хранилище
+type sessions map[uint32]*Session
+
+func (this sessions) prune() {
+	for id, s := range this {
+		if s.IsExpired() {
+			delete(this, id)
+		}
+	}
+}
+
+func (this sessions) issue(accountId uint32, ip string, ttl time.Duration) (Session, error) {
+	this.prune()
+
+	id, err := generateId(func(id uint32) bool {
+		_, exists := this[id]
+		return exists
+	})
+
+	if err != nil {
+		return Session{}, err
+	}
+
+	now := time.Now()
+	s := &Session{
+		Id:        id,
+		AccountId: accountId,
+		IP:        ip,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	this[id] = s
+
+	return *s, nil
+}
+
+func (this sessions) bind(sessionId uint32, serverId uint16) error {
+	s, exists := this[sessionId]
+
+	if !exists {
+		return ErrNotFound
+	}
+
+	if s.IsExpired() {
+		delete(this, sessionId)
+		return ErrExpired
+	}
+
+	s.ServerId = serverId
+
+	return nil
+}
+
+func (this sessions) consume(sessionId uint32, ip string, serverId uint16) (Session, error) {
+	s, exists := this[sessionId]
+
+	if !exists {
+		return Session{}, ErrNotFound
+	}
+
+	if s.IsExpired() {
+		delete(this, sessionId)
+		return *s, ErrExpired
+	}
+
+	if s.IP != ip {
+		return *s, ErrWrongIP
+	}
+
+	if s.ServerId == 0 {
+		return *s, ErrNotBound
+	}
+
+	if s.ServerId != serverId {
+		return *s, ErrWrongServer
+	}
+
+	delete(this, sessionId)
+
+	return *s, nil
+}
+
+// Хранилище сессий в памяти. Подходит если логин-сервер и игровой сервер работают в одном процессе
+type MemoryStore struct {
+	sessions sessions
+	mu       sync.Mutex
+}
+
+func (this *MemoryStore) Issue(accountId uint32, ip string, ttl time.Duration) (Session, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.sessions.issue(accountId, ip, ttl)
+}
+
+func (this *MemoryStore) Bind(sessionId uint32, serverId uint16) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.sessions.bind(sessionId, serverId)
+}
+
+func (this *MemoryStore) Consume(sessionId uint32, ip string, serverId uint16) (Session, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.sessions.consume(sessionId, ip, serverId)
+}
+
+// Создает хранилище сессий в памяти
+func CreateMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: make(sessions),
+	}
+}
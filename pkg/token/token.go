@@ -0,0 +1,235 @@
+package token
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/launcher"
+	"github.com/tuxuuman/r2o-core/pkg/login"
+)
+
+const (
+	// Версия формата токена
+	VERSION uint8 = 1
+	// Размер подписи в байтах (усеченный HMAC-SHA256)
+	SIGNATURE_LENGTH = 16
+	// Максимальная длинна токена, чтобы он поместился в параметр P4
+	MAX_LENGTH = launcher.TOKEN_MAX_LENGTH
+)
+
+var (
+	ErrInvalidToken = errors.New("Некорректный токен")
+	ErrInvalidSign  = errors.New("Неверная подпись токена")
+	ErrExpiredToken = errors.New("Срок действия токена истек")
+	ErrWrongIP      = errors.New("Токен выдан для другого IP")
+	ErrReusedToken  = errors.New("Токен уже был использован")
+)
+
+// Данные токена.
+//
+// Время хранится с точностью до секунды.
+type Claims struct {
+	// Id аккаунта
+	AccountId uint32
+	// Время выдачи
+	IssuedAt time.Time
+	// Время окончания действия
+	ExpiresAt time.Time
+	// IP, с которого разрешено использовать токен. nil - с любого
+	IP net.IP
+	// Случайное число, делающее токен уникальным
+	Nonce uint64
+}
+
+// Бинарное представление данных токена. Нельзя менять порядок полей
+type payload struct {
+	Version   uint8
+	AccountId uint32
+	IssuedAt  uint32
+	ExpiresAt uint32
+	IP        [4]byte
+	Nonce     uint64
+}
+
+func sign(secret []byte, data []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return mac.Sum(nil)[:SIGNATURE_LENGTH]
+}
+
+// Выпускает подписанные токены. Например на сайте, для параметра запуска P4
+type Issuer struct {
+	secret []byte
+}
+
+// Выпустить токен
+//
+// "accountId" - id аккаунта
+//
+// "ttl" - время действия токена
+//
+// "ip" - IP (v4), с которого разрешено использовать токен. nil - с любого
+func (this *Issuer) Issue(accountId uint32, ttl time.Duration, ip net.IP) (string, error) {
+	now := time.Now()
+
+	p := payload{
+		Version:   VERSION,
+		AccountId: accountId,
+		IssuedAt:  uint32(now.Unix()),
+		ExpiresAt: uint32(now.Add(ttl).Unix()),
+	}
+
+	if ip != nil {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return "", errors.New(fmt.Sprintf("Поддерживаются только IPv4 адреса [%v]", ip))
+		}
+		copy(p.IP[:], ip4)
+	}
+
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	p.Nonce = binary.LittleEndian.Uint64(nonce)
+
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, p); err != nil {
+		return "", err
+	}
+
+	data := buf.Bytes()
+	token := base64.RawURLEncoding.EncodeToString(append(data, sign(this.secret, data)...))
+
+	if len(token) > MAX_LENGTH {
+		return "", errors.New(fmt.Sprintf("Длинна токена [%v] превышает допустимую [%v]", len(token), MAX_LENGTH))
+	}
+
+	return token, nil
+}
+
+// Создает выпускающего токены с секретным ключем "secret"
+func CreateIssuer(secret []byte) *Issuer {
+	return &Issuer{secret: secret}
+}
+
+// Проверяет токены и не дает использовать один токен дважды.
+//
+// Может использоваться в логин-сервере как login.Authenticator.
+type Verifier struct {
+	secret []byte
+	used   map[uint64]time.Time
+	mu     sync.Mutex
+	// Допустимое расхождение часов между сайтом и сервером (По умолчанию: 30 сек)
+	ClockSkew time.Duration
+	// Id ошибки, отправляемой клиенту при неудачной проверке токена (По умолчанию: login.ERROR_INVALID_SESSION)
+	ErrorId uint32
+}
+
+// Проверить токен без отметки об использовании
+//
+// "ip" - IP клиента
+func (this *Verifier) Parse(token string, ip string) (Claims, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+
+	if err != nil || len(raw) <= SIGNATURE_LENGTH {
+		return Claims{}, ErrInvalidToken
+	}
+
+	data := raw[:len(raw)-SIGNATURE_LENGTH]
+
+	if !hmac.Equal(raw[len(raw)-SIGNATURE_LENGTH:], sign(this.secret, data)) {
+		return Claims{}, ErrInvalidSign
+	}
+
+	p := payload{}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &p); err != nil || p.Version != VERSION {
+		return Claims{}, ErrInvalidToken
+	}
+
+	claims := Claims{
+		AccountId: p.AccountId,
+		IssuedAt:  time.Unix(int64(p.IssuedAt), 0),
+		ExpiresAt: time.Unix(int64(p.ExpiresAt), 0),
+		Nonce:     p.Nonce,
+	}
+
+	if p.IP != [4]byte{} {
+		claims.IP = net.IPv4(p.IP[0], p.IP[1], p.IP[2], p.IP[3])
+	}
+
+	now := time.Now()
+
+	if now.After(claims.ExpiresAt.Add(this.ClockSkew)) || now.Add(this.ClockSkew).Before(claims.IssuedAt) {
+		return claims, ErrExpiredToken
+	}
+
+	if claims.IP != nil && !claims.IP.Equal(net.ParseIP(ip)) {
+		return claims, ErrWrongIP
+	}
+
+	return claims, nil
+}
+
+// Проверить токен и отметить его использованным. Повторная проверка того же токена вернет ErrReusedToken
+//
+// "ip" - IP клиента
+func (this *Verifier) Verify(token string, ip string) (Claims, error) {
+	claims, err := this.Parse(token, ip)
+
+	if err != nil {
+		return claims, err
+	}
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	now := time.Now()
+
+	// использованные токены нужно помнить только до окончания их действия
+	for nonce, expiresAt := range this.used {
+		if now.After(expiresAt.Add(this.ClockSkew)) {
+			delete(this.used, nonce)
+		}
+	}
+
+	if _, used := this.used[claims.Nonce]; used {
+		return claims, ErrReusedToken
+	}
+
+	this.used[claims.Nonce] = claims.ExpiresAt
+
+	return claims, nil
+}
+
+// Реализация login.Authenticator
+func (this *Verifier) Authenticate(token string, ip string) (uint32, uint32, uint32) {
+	claims, err := this.Verify(token, ip)
+
+	if err != nil {
+		log.Printf("Токен клиента %v отклонен: %v", ip, err)
+		return 0, 0, this.ErrorId
+	}
+
+	return claims.AccountId, login.GenerateSessionId(), 0
+}
+
+// Создает проверяющего токены с секретным ключем "secret"
+func CreateVerifier(secret []byte) *Verifier {
+	return &Verifier{
+		secret:    secret,
+		used:      make(map[uint64]time.Time),
+		ClockSkew: time.Second * 30,
+		ErrorId:   login.ERROR_INVALID_SESSION,
+	}
+}
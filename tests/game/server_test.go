@@ -0,0 +1,109 @@
+package game
+
+import (
+	gonet "net"
+	"testing"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/game"
+	"github.com/tuxuuman/r2o-core/pkg/login"
+	"github.com/tuxuuman/r2o-core/pkg/net"
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+	"github.com/tuxuuman/r2o-core/pkg/session"
+)
+
+const HANDSHAKE_PACKET_ID uint16 = 9999
+
+// Подключиться и отправить первым пакет "first"
+func connectWith(t *testing.T, first *packet.Packet) gonet.Conn {
+	conn, err := gonet.Dial("tcp", "127.0.0.1:18105")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+
+	// пакет разрешения подключения
+	if _, err := packet.ReadPacket(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.Write(first.Bytes())
+
+	return conn
+}
+
+func connect(t *testing.T, sessionId uint32) gonet.Conn {
+	return connectWith(t, packet.CreatePacketOrPanic(HANDSHAKE_PACKET_ID, sessionId))
+}
+
+func TestHandshake(t *testing.T) {
+	sessions := session.CreateMemoryStore()
+	clients := make(chan *game.Client, 1)
+
+	server := game.CreateServer("127.0.0.1", 18105, 1, sessions)
+
+	if server.HandshakePacketId != game.PACKET_HANDSHAKE {
+		t.Fatal("Неправильный id пакета с id сессии по умолчанию", server.HandshakePacketId)
+	}
+
+	server.HandshakePacketId = HANDSHAKE_PACKET_ID
+	server.OnClient = func(c *game.Client) {
+		clients <- c
+	}
+	go server.Start()
+	defer server.Stop()
+	time.Sleep(time.Millisecond * 100)
+
+	s, _ := sessions.Issue(7, "127.0.0.1", time.Minute)
+	sessions.Bind(s.Id, 1)
+
+	conn := connect(t, s.Id)
+	defer conn.Close()
+
+	select {
+	case c := <-clients:
+		if c.Session.AccountId != 7 || c.AccountId() != 7 {
+			t.Fatal("Неправильный аккаунт клиента", c.Session.AccountId)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Клиент не прошел проверку сессии")
+	}
+
+	// повторное использование сессии
+	reused := connect(t, s.Id)
+	defer reused.Close()
+
+	p, err := packet.ReadPacket(reused)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Id != 3102 {
+		t.Fatal("Ожидалась критическая ошибка", p.Id)
+	}
+
+	// игровой пакет без пакета с id сессии
+	skipped := connectWith(t, packet.CreatePacketOrPanic(5000, uint32(1)))
+	defer skipped.Close()
+
+	p, err = packet.ReadPacket(skipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fatal := net.FatalErrorPacket{}
+	if p.Id != net.PACKET_FATAL_ERROR || p.Read(&fatal) != nil || fatal.ErrorId != login.ERROR_INVALID_SESSION {
+		t.Fatal("Ожидалась ошибка сессии", p.Id, fatal)
+	}
+
+	if _, err := packet.ReadPacket(skipped); err == nil {
+		t.Fatal("Клиент без пакета с id сессии не отключен")
+	}
+
+	select {
+	case <-clients:
+		t.Fatal("Клиент без пакета с id сессии передан в OnClient")
+	default:
+	}
+}
@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/internal/ratelimit"
+)
+
+func TestTokenBucket(t *testing.T) {
+	bucket := ratelimit.CreateTokenBucket(100, 3)
+
+	for i := 0; i < 3; i++ {
+		if !bucket.Allow() {
+			t.Fatal("Токен не выдан в пределах burst", i)
+		}
+	}
+
+	if bucket.Allow() {
+		t.Fatal("Выдан токен сверх burst")
+	}
+
+	time.Sleep(time.Millisecond * 20)
+
+	if !bucket.Allow() {
+		t.Fatal("Корзина не пополнилась")
+	}
+}
+
+func TestTokenBucketHasToken(t *testing.T) {
+	bucket := ratelimit.CreateTokenBucket(0.01, 1)
+
+	// проверка не забирает токен
+	if !bucket.HasToken() || !bucket.HasToken() {
+		t.Fatal("Нет токена в полной корзине")
+	}
+
+	if !bucket.Allow() || bucket.HasToken() {
+		t.Fatal("Токен не забран")
+	}
+}
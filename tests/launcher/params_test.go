@@ -0,0 +1,40 @@
+package launcher
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tuxuuman/r2o-core/pkg/launcher"
+)
+
+const ETALON_PARAMS = "P0=cXdlcnR5&P1=Q19SMg==&P2=NDYxMg==&P4=cXdlcnR5&PC1=Tg==&PC2=Tg=="
+
+func TestParse(t *testing.T) {
+	params, err := launcher.Parse(ETALON_PARAMS)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if params.Login != "qwerty" || params.Token != "qwerty" || params.P1 != "C_R2" || params.P2 != "4612" || params.PC1 || params.PC2 {
+		t.Fatal("Параметры разобраны неправильно", params)
+	}
+
+	if params.String() != ETALON_PARAMS {
+		t.Fatalf("Сгенерирована неправильная строка. Ожидаемый результат: %v. Полученый результат: %v", ETALON_PARAMS, params.String())
+	}
+}
+
+func TestCreateParams(t *testing.T) {
+	params := launcher.CreateParams("qwerty", "qwerty")
+
+	if params.String() != ETALON_PARAMS {
+		t.Fatalf("Сгенерирована неправильная строка. Ожидаемый результат: %v. Полученый результат: %v", ETALON_PARAMS, params.String())
+	}
+
+	params.Token = strings.Repeat("a", launcher.TOKEN_MAX_LENGTH+1)
+
+	if params.Validate() == nil {
+		t.Fatal("Слишком длинный токен прошел проверку")
+	}
+}
@@ -0,0 +1,70 @@
+package login
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/tuxuuman/r2o-core/pkg/login"
+)
+
+func TestStaticAuthenticator(t *testing.T) {
+	auth := login.CreateStaticAuthenticator(map[string]uint32{"qwerty": 1})
+
+	accountId, sessionId, errorId := auth.Authenticate("qwerty", "127.0.0.1")
+	if accountId != 1 || sessionId == 0 || errorId != 0 {
+		t.Fatal("Авторизация не удалась", accountId, sessionId, errorId)
+	}
+
+	if _, _, errorId := auth.Authenticate("asdfgh", "127.0.0.1"); errorId != login.ERROR_INVALID_SESSION {
+		t.Fatal("Неизвестный токен прошел авторизацию", errorId)
+	}
+}
+
+func TestJSONAuthenticator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	data := `[
+		{"token": "qwerty", "accountId": 1, "ips": ["127.0.0.1"]},
+		{"token": "asdfgh", "accountId": 2, "blocked": true}
+	]`
+
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	auth, err := login.CreateJSONAuthenticator(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if accountId, _, errorId := auth.Authenticate("qwerty", "127.0.0.1"); accountId != 1 || errorId != 0 {
+		t.Fatal("Авторизация не удалась", accountId, errorId)
+	}
+
+	if _, _, errorId := auth.Authenticate("qwerty", "127.0.0.2"); errorId == 0 {
+		t.Fatal("Авторизация прошла с неразрешенного IP")
+	}
+
+	if _, _, errorId := auth.Authenticate("asdfgh", "127.0.0.1"); errorId == 0 {
+		t.Fatal("Заблокированный аккаунт прошел авторизацию")
+	}
+}
+
+func TestCommandAuthenticator(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh не найден")
+	}
+
+	script := `read req; case "$req" in *qwerty*) echo '{"accountId": 5, "sessionId": 42}';; *) echo '{"errorId": 1}';; esac`
+	auth := login.CreateCommandAuthenticator(sh, "-c", script)
+
+	if accountId, sessionId, errorId := auth.Authenticate("qwerty", "127.0.0.1"); accountId != 5 || sessionId != 42 || errorId != 0 {
+		t.Fatal("Авторизация не удалась", accountId, sessionId, errorId)
+	}
+
+	if _, _, errorId := auth.Authenticate("asdfgh", "127.0.0.1"); errorId != 1 {
+		t.Fatal("Неверный токен прошел авторизацию", errorId)
+	}
+}
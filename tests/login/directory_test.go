@@ -0,0 +1,51 @@
+package login
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/login"
+)
+
+func TestDirectory(t *testing.T) {
+	dir := login.CreateDirectory(
+		login.GameServerInfo{Id: 2, Online: true, Name: login.MakeServerName("Server 2")},
+		login.GameServerInfo{Id: 1, Online: true, Name: login.MakeServerName("Server 1")},
+	)
+	dir.WorkloadThresholds = []uint16{10, 20}
+	dir.HeartbeatTimeout = time.Millisecond * 50
+
+	dir.ReportPlayers(1, 15)
+
+	servers := dir.GameServers()
+	if len(servers) != 2 || servers[0].Id != 1 || servers[1].Id != 2 {
+		t.Fatal("Неправильный список серверов", servers)
+	}
+
+	if servers[0].Workload != 1 || servers[1].Workload != 0 {
+		t.Fatal("Неправильная загруженность", servers[0].Workload, servers[1].Workload)
+	}
+
+	time.Sleep(time.Millisecond * 60)
+	dir.Heartbeat(2)
+
+	if gs, _ := dir.Get(1); gs.Online {
+		t.Fatal("Сервер без heartbeat доступен")
+	}
+
+	if gs, _ := dir.Get(2); !gs.Online {
+		t.Fatal("Сервер с heartbeat недоступен")
+	}
+
+	dir.Update(2, func(info *login.GameServerInfo) {
+		info.Hidden = 1
+	})
+
+	if gs, _ := dir.Get(2); gs.Hidden != 1 {
+		t.Fatal("Информация о сервере не изменилась")
+	}
+
+	if !dir.Remove(1) || len(dir.GameServers()) != 1 {
+		t.Fatal("Сервер не удален")
+	}
+}
@@ -0,0 +1,130 @@
+package login
+
+import (
+	gonet "net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/login"
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+func waitFor(t *testing.T, check func() bool) {
+	for i := 0; i < 100; i++ {
+		if check() {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatal("Не дождались изменения списка серверов")
+}
+
+func TestRegistration(t *testing.T) {
+	dir := login.CreateDirectory()
+	dir.WorkloadThresholds = []uint16{10}
+
+	regServer := login.CreateRegistrationServerOrPanic(dir, "secret")
+	go regServer.Listen("tcp", "127.0.0.1:18110")
+	defer regServer.Close()
+	time.Sleep(time.Millisecond * 100)
+
+	info := login.GameServerInfo{Id: 3, ListId: 1, Ip: [4]byte{127, 0, 0, 1}, Port: 11005, Name: login.MakeServerName("Server 3")}
+
+	if _, err := login.RegisterGameServer("tcp", "127.0.0.1:18110", "wrong", info); err == nil {
+		t.Fatal("Регистрация с неверным ключем прошла")
+	}
+
+	reg, err := login.RegisterGameServer("tcp", "127.0.0.1:18110", "secret", info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gs, exists := dir.Get(3); !exists || !gs.Online || gs.GetName() != "Server 3" {
+		t.Fatal("Сервер не зарегистрирован", gs)
+	}
+
+	if err := reg.Heartbeat(15); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool {
+		gs, _ := dir.Get(3)
+		return gs.Workload == 1
+	})
+
+	if err := reg.Deregister(); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool {
+		_, exists := dir.Get(3)
+		return !exists
+	})
+}
+
+func TestRegistrationSecret(t *testing.T) {
+	dir := login.CreateDirectory()
+
+	if _, err := login.CreateRegistrationServer(dir, ""); err == nil {
+		t.Fatal("Пустой секретный ключ принят")
+	}
+
+	if _, err := login.CreateRegistrationServer(dir, strings.Repeat("s", login.REG_SECRET_LENGTH+1)); err == nil {
+		t.Fatal("Слишком длинный секретный ключ принят")
+	}
+
+	if _, err := login.CreateRegistrationServer(dir, strings.Repeat("s", login.REG_SECRET_LENGTH)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistrationReconnect(t *testing.T) {
+	dir := login.CreateDirectory()
+
+	regServer := login.CreateRegistrationServerOrPanic(dir, "secret")
+	go regServer.Listen("tcp", "127.0.0.1:18120")
+	defer regServer.Close()
+	time.Sleep(time.Millisecond * 100)
+
+	info := login.GameServerInfo{Id: 4, ListId: 1, Ip: [4]byte{127, 0, 0, 1}, Port: 11005, Name: login.MakeServerName("Server 4")}
+
+	// старое соединение игрового сервера, которое оборвется после повторной регистрации
+	old, err := gonet.Dial("tcp", "127.0.0.1:18120")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := login.RegisterRequest{Info: info}
+	copy(req.Secret[:], "secret")
+	old.Write(packet.CreatePacketOrPanic(login.REG_PACKET_REGISTER, &req).Bytes())
+
+	old.SetReadDeadline(time.Now().Add(time.Second * 5))
+	if _, err := packet.ReadPacket(old); err != nil {
+		t.Fatal(err)
+	}
+
+	reg, err := login.RegisterGameServer("tcp", "127.0.0.1:18120", "secret", info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reg.Deregister()
+
+	old.Close()
+
+	// обрыв старого соединения не должен сделать недоступной новую регистрацию
+	time.Sleep(time.Millisecond * 100)
+
+	if gs, exists := dir.Get(4); !exists || !gs.Online {
+		t.Fatal("Сервер стал недоступен после обрыва старого соединения", gs)
+	}
+
+	if err := reg.Heartbeat(1); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool {
+		players, _ := dir.Players(4)
+		return players == 1
+	})
+}
@@ -0,0 +1,123 @@
+package login
+
+import (
+	"encoding/binary"
+	"io"
+	gonet "net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/login"
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+const SERVER_ADDRESS = "127.0.0.1:18104"
+
+func readPacket(t *testing.T, conn gonet.Conn) *packet.Packet {
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+
+	bufLen := make([]byte, 2)
+	if _, err := io.ReadFull(conn, bufLen); err != nil {
+		t.Fatal(err)
+	}
+
+	bufPac := make([]byte, binary.LittleEndian.Uint16(bufLen)-2)
+	if _, err := io.ReadFull(conn, bufPac); err != nil {
+		t.Fatal(err)
+	}
+
+	p := packet.CreatePacketFromBytesOrPanic(append(bufLen, bufPac...))
+	p.Decrypt()
+	return p
+}
+
+func TestLoginFlow(t *testing.T) {
+	servers := login.StaticDirectory{
+		{Id: 1, Online: true, ListId: 1, Ip: [4]byte{127, 0, 0, 1}, Port: 11005, Name: login.MakeServerName("Server 1")},
+	}
+
+	auth := login.AuthenticatorFunc(func(token string, ip string) (uint32, uint32, uint32) {
+		if token != "qwerty" {
+			return 0, 0, login.ERROR_INVALID_SESSION
+		}
+		return 7, 123456, 0
+	})
+
+	capturePath := filepath.Join(t.TempDir(), "login.r2pac")
+	capture, err := packet.CreateCaptureFile(capturePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer capture.Close()
+
+	server := login.CreateServer("127.0.0.1", 18104, auth, servers)
+	server.Capture = capture
+	go server.Start()
+	defer server.Stop()
+	time.Sleep(time.Millisecond * 100)
+
+	conn, err := gonet.Dial("tcp", SERVER_ADDRESS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// пакет разрешения подключения
+	readPacket(t, conn)
+
+	authReq := login.AuthRequest{}
+	copy(authReq.P4[:], "qwerty")
+	conn.Write(packet.CreatePacketOrPanic(login.PACKET_AUTH_REQUEST, &authReq).Bytes())
+
+	p := readPacket(t, conn)
+	if p.Id != login.PACKET_AUTH_RESULT {
+		t.Fatal("Ожидался пакет результата авторизации", p.Id)
+	}
+
+	var accountId, sessionId uint32
+	var count uint8
+	list := make([]login.GameServerInfo, 1)
+	if err := p.Read(&accountId, &sessionId, &count, list); err != nil {
+		t.Fatal(err)
+	}
+
+	if accountId != 7 || sessionId != 123456 || count != 1 || list[0].GetName() != "Server 1" {
+		t.Fatal("Неправильный результат авторизации", accountId, sessionId, count, list[0].GetName())
+	}
+
+	connReq := login.ConnectRequest{SessionId: sessionId, ServerId: 1}
+	copy(connReq.P0[:], "player")
+	conn.Write(packet.CreatePacketOrPanic(login.PACKET_CONNECT_REQUEST, &connReq).Bytes())
+
+	p = readPacket(t, conn)
+	if p.Id != login.PACKET_CONNECT_RESULT {
+		t.Fatal("Ожидался пакет разрешения подключения к игровому серверу", p.Id)
+	}
+
+	records, err := packet.ReadCaptureFile(capturePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []uint16{1103, login.PACKET_AUTH_REQUEST, login.PACKET_AUTH_RESULT, login.PACKET_CONNECT_REQUEST, login.PACKET_CONNECT_RESULT}
+	if len(records) != len(ids) {
+		t.Fatal("Неправильное кол-во записанных пакетов", len(records))
+	}
+
+	for i, rec := range records {
+		p, err := rec.Packet()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		direction := packet.DIRECTION_SERVER_TO_CLIENT
+		if i%2 == 1 {
+			direction = packet.DIRECTION_CLIENT_TO_SERVER
+		}
+
+		if p.Id != ids[i] || rec.Direction != direction || rec.ConnId != records[0].ConnId {
+			t.Fatal("Неправильная запись пакета", i, p.Id, rec.Direction, rec.ConnId)
+		}
+	}
+}
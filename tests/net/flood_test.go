@@ -0,0 +1,158 @@
+package net
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/net"
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+const (
+	DROP_PACKET_ID       uint16 = 5000
+	ERROR_PACKET_ID      uint16 = 5001
+	DISCONNECT_PACKET_ID uint16 = 5002
+	MARKER_PACKET_ID     uint16 = 5003
+)
+
+func TestPacketRateLimits(t *testing.T) {
+	// id пакетов, дошедших до OnPacket и до обработчиков
+	seen := make(chan uint16, 100)
+	handled := make(chan uint16, 100)
+
+	limit := func(action net.RateLimitAction) net.PacketRateLimit {
+		return net.PacketRateLimit{Rate: 0.01, Burst: 1, Action: action, ErrorId: 77}
+	}
+
+	server := startServer(t, 18113, func(s *net.Server) {
+		s.PacketRateLimits[DROP_PACKET_ID] = limit(net.RATE_LIMIT_ACTION_DROP)
+		s.PacketRateLimits[ERROR_PACKET_ID] = limit(net.RATE_LIMIT_ACTION_ERROR)
+		s.PacketRateLimits[DISCONNECT_PACKET_ID] = limit(net.RATE_LIMIT_ACTION_DISCONNECT)
+	}, func(s *net.Server, c *net.Client) {
+		c.OnPacket(func(p *packet.Packet) {
+			seen <- p.Id
+		}, false)
+		for _, id := range []uint16{DROP_PACKET_ID, ERROR_PACKET_ID, DISCONNECT_PACKET_ID, MARKER_PACKET_ID} {
+			c.SetPacketHandler(id, func(p *packet.Packet, data interface{}) {
+				handled <- p.Id
+			}, nil, false)
+		}
+	})
+	defer server.Stop()
+
+	conn, p := connect(t, 18113)
+	defer conn.Close()
+	expectAccepted(t, p)
+
+	send := func(id uint16) {
+		p := packet.CreatePacketOrPanic(id, uint32(1))
+		p.Encrypt()
+		if _, err := conn.Write(p.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// пакеты обрабатываются по порядку, поэтому после маркера все предыдущие пакеты уже обработаны
+	expectPackets := func(ids ...uint16) {
+		t.Helper()
+		send(MARKER_PACKET_ID)
+		ids = append(ids, MARKER_PACKET_ID)
+
+		for _, ch := range []chan uint16{seen, handled} {
+			for _, id := range ids {
+				select {
+				case got := <-ch:
+					if got != id {
+						t.Fatalf("Получен пакет [%v], ожидался [%v]", got, id)
+					}
+				case <-time.After(time.Second * 5):
+					t.Fatalf("Не получен пакет [%v]", id)
+				}
+			}
+		}
+	}
+
+	// RATE_LIMIT_ACTION_DROP: второй пакет отбрасывается без ответа
+	send(DROP_PACKET_ID)
+	send(DROP_PACKET_ID)
+	expectPackets(DROP_PACKET_ID)
+
+	// RATE_LIMIT_ACTION_ERROR: второй пакет отбрасывается, клиенту отправляется ошибка
+	send(ERROR_PACKET_ID)
+	send(ERROR_PACKET_ID)
+	expectPackets(ERROR_PACKET_ID)
+
+	p, err := packet.ReadPacket(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Decrypt()
+
+	errorPacket := net.ErrorPacket{}
+	if p.Id != net.PACKET_ERROR || p.Read(&errorPacket) != nil || errorPacket.PacketId != ERROR_PACKET_ID || errorPacket.ErrorId != 77 {
+		t.Fatal("Ожидалась ошибка на пакет", ERROR_PACKET_ID, p.Id, errorPacket)
+	}
+
+	// RATE_LIMIT_ACTION_DISCONNECT: клиент отключается
+	send(DISCONNECT_PACKET_ID)
+	send(DISCONNECT_PACKET_ID)
+
+	if _, err := packet.ReadPacket(conn); err != io.EOF {
+		t.Fatal("Ожидалось отключение клиента", err)
+	}
+
+	if len(seen) != 1 || <-seen != DISCONNECT_PACKET_ID {
+		t.Fatal("Отброшенный пакет передан в OnPacket")
+	}
+
+	stats := server.RateLimitStats()
+	if stats.Dropped != 3 || stats.Errors != 1 || stats.Disconnects != 1 {
+		t.Fatal("Неправильная статистика ограничений", stats)
+	}
+}
+
+func TestPacketRateLimitBudget(t *testing.T) {
+	handled := make(chan uint16, 100)
+
+	server := startServer(t, 18122, func(s *net.Server) {
+		s.ClientRateLimit = net.PacketRateLimit{Rate: 10, Burst: 1, Action: net.RATE_LIMIT_ACTION_DROP}
+		s.PacketRateLimits[DROP_PACKET_ID] = net.PacketRateLimit{Rate: 0.01, Burst: 2, Action: net.RATE_LIMIT_ACTION_DROP}
+	}, func(s *net.Server, c *net.Client) {
+		c.SetPacketHandler(DROP_PACKET_ID, func(p *packet.Packet, data interface{}) {
+			handled <- p.Id
+		}, nil, false)
+	})
+	defer server.Stop()
+
+	conn, p := connect(t, 18122)
+	defer conn.Close()
+	expectAccepted(t, p)
+
+	send := func() {
+		if _, err := conn.Write(packet.CreatePacketOrPanic(DROP_PACKET_ID).Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// второй пакет отбрасывается общим ограничением клиента и не должен расходовать ограничение по id
+	send()
+	send()
+	waitFor(t, func() bool { return server.RateLimitStats().Dropped == 1 }, "Пакет не отброшен общим ограничением")
+
+	// общее ограничение пополнилось, а у ограничения по id остался токен
+	time.Sleep(time.Millisecond * 200)
+	send()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-handled:
+		case <-time.After(time.Second * 5):
+			t.Fatal("Пакет отброшен ограничением по id", i)
+		}
+	}
+
+	if stats := server.RateLimitStats(); stats.Dropped != 1 {
+		t.Fatal("Неправильная статистика ограничений", stats)
+	}
+}
@@ -0,0 +1,156 @@
+package net
+
+import (
+	gonet "net"
+	"testing"
+
+	"github.com/tuxuuman/r2o-core/pkg/net"
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+const (
+	MAINTENANCE_ERROR_ID uint32 = 55
+	// пакет авторизации с id аккаунта
+	LOGIN_PACKET_ID uint16 = 5100
+	// ответ на успешную авторизацию
+	LOGGED_IN_PACKET_ID uint16 = 5101
+)
+
+// Упрощенная авторизация: клиент присылает id аккаунта и получает отказ, если ему не разрешен доступ во время технических работ
+func handleLogin(s *net.Server, c *net.Client) {
+	c.SetPacketHandler(LOGIN_PACKET_ID, func(p *packet.Packet, data interface{}) {
+		c.SetAccountId(*data.(*uint32))
+
+		if !s.IsAllowedDuringMaintenance(c) {
+			c.FatalError(s.MaintenanceErrorId)
+			c.Close()
+			return
+		}
+
+		c.SendPacket(packet.CreatePacketOrPanic(LOGGED_IN_PACKET_ID))
+	}, new(uint32), false)
+}
+
+// Подключиться и авторизоваться. Возвращает ответ на авторизацию
+func login(t *testing.T, port uint16, accountId uint32) (gonet.Conn, *packet.Packet) {
+	t.Helper()
+
+	conn, p := connect(t, port)
+	expectAccepted(t, p)
+
+	if _, err := conn.Write(packet.CreatePacketOrPanic(LOGIN_PACKET_ID, accountId).Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	return conn, read(t, conn)
+}
+
+func expectLoggedIn(t *testing.T, p *packet.Packet) {
+	t.Helper()
+
+	if p.Id != LOGGED_IN_PACKET_ID {
+		t.Fatalf("Ожидалась успешная авторизация, получен пакет [%v]", p.Id)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	server := startServer(t, 18117, func(s *net.Server) {
+		s.MaintenanceErrorId = MAINTENANCE_ERROR_ID
+	}, handleLogin)
+	defer server.Stop()
+
+	server.EnableMaintenance(false)
+
+	if !server.IsMaintenance() {
+		t.Fatal("Режим технических работ не включен")
+	}
+
+	// подключение принимается, а отказ происходит после авторизации
+	conn, p := login(t, 18117, 1)
+	conn.Close()
+	expectRejected(t, p, MAINTENANCE_ERROR_ID)
+
+	// разрешенный аккаунт с любого IP
+	server.AllowMaintenanceAccount(2)
+	gm, p := login(t, 18117, 2)
+	defer gm.Close()
+	expectLoggedIn(t, p)
+
+	// разрешенный IP
+	server.AllowMaintenanceIP("127.0.0.1")
+	conn, p = login(t, 18117, 1)
+	conn.Close()
+	expectLoggedIn(t, p)
+	server.DisallowMaintenanceIP("127.0.0.1")
+
+	server.DisableMaintenance()
+
+	if server.IsMaintenance() {
+		t.Fatal("Режим технических работ не выключен")
+	}
+
+	player, p := login(t, 18117, 1)
+	defer player.Close()
+	expectLoggedIn(t, p)
+
+	// отключение клиентов без доступа
+	server.EnableMaintenance(true)
+	expectRejected(t, read(t, player), MAINTENANCE_ERROR_ID)
+
+	if _, err := packet.ReadPacket(player); err == nil {
+		t.Fatal("Клиент без доступа не отключен")
+	}
+
+	// разрешенный аккаунт остается подключенным
+	if _, err := gm.Write(packet.CreatePacketOrPanic(LOGIN_PACKET_ID, uint32(2)).Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	expectLoggedIn(t, read(t, gm))
+
+	server.DisableMaintenance()
+}
+
+func TestMaintenanceRejectByIP(t *testing.T) {
+	server := startServer(t, 18118, func(s *net.Server) {
+		s.MaintenanceErrorId = MAINTENANCE_ERROR_ID
+		s.MaintenanceRejectByIP = true
+	}, handleLogin)
+	defer server.Stop()
+
+	server.EnableMaintenance(false)
+	server.AllowMaintenanceAccount(2)
+
+	// подключение отклоняется сразу, даже для разрешенного аккаунта
+	conn, p := connect(t, 18118)
+	conn.Close()
+	expectRejected(t, p, MAINTENANCE_ERROR_ID)
+
+	server.AllowMaintenanceIP("127.0.0.1")
+	conn, p = login(t, 18118, 1)
+	conn.Close()
+	expectLoggedIn(t, p)
+}
+
+func TestMaintenanceQueue(t *testing.T) {
+	server := startServer(t, 18119, func(s *net.Server) {
+		s.MaintenanceErrorId = MAINTENANCE_ERROR_ID
+		s.MaxClientsCount = 1
+		s.MaxQueueLength = 1
+	}, handleLogin)
+	defer server.Stop()
+
+	player, p := login(t, 18119, 1)
+	defer player.Close()
+	expectLoggedIn(t, p)
+
+	queued := dial(t, 18119)
+	defer queued.Close()
+	waitFor(t, func() bool { return server.GetQueueLength() == 1 }, "Клиент не поставлен в очередь")
+
+	server.EnableMaintenance(true)
+
+	expectRejected(t, read(t, queued), MAINTENANCE_ERROR_ID)
+	expectRejected(t, read(t, player), MAINTENANCE_ERROR_ID)
+
+	waitFor(t, func() bool { return server.GetQueueLength() == 0 }, "Клиент остался в очереди")
+}
@@ -0,0 +1,128 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+	"github.com/tuxuuman/r2o-core/resources"
+)
+
+func TestCapture(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	w, err := packet.CreateCaptureWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := packet.CreatePacketOrPanic(5000, uint32(7))
+	p.Encrypt()
+
+	connId := w.NewConnId()
+	at := time.Unix(1700000000, 123)
+
+	if err := w.Write(packet.Record{Time: at, Direction: packet.DIRECTION_CLIENT_TO_SERVER, ConnId: connId, Data: p.Bytes()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.WritePacket(packet.DIRECTION_SERVER_TO_CLIENT, w.NewConnId(), packet.CreatePacketOrPanic(5001)); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := packet.CreateCaptureReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rec.Time.Equal(at) || rec.Direction != packet.DIRECTION_CLIENT_TO_SERVER || rec.ConnId != connId || !bytes.Equal(rec.Data, p.Bytes()) {
+		t.Fatal("Неправильная запись", rec)
+	}
+
+	// пакет сохраняется в том виде, в котором был передан
+	rp, err := rec.Packet()
+	if err != nil || !rp.IsEncrypted() || rp.Id != 5000 {
+		t.Fatal("Неправильный пакет из записи", rp, err)
+	}
+
+	if rec, err = r.Next(); err != nil || rec.ConnId == connId || rec.Direction != packet.DIRECTION_SERVER_TO_CLIENT {
+		t.Fatal("Неправильная вторая запись", rec, err)
+	}
+
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatal("Ожидался конец записи", err)
+	}
+
+	// запись обрывается на середине первого пакета
+	r, err = packet.CreateCaptureReader(bytes.NewReader(buf.Bytes()[:40]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Next(); err == nil || err == io.EOF {
+		t.Fatal("Оборванная запись прочитана без ошибки", err)
+	}
+
+	// поврежденный заголовок записи с огромной длинной пакета
+	corrupt := append([]byte{}, buf.Bytes()...)
+	binary.LittleEndian.PutUint32(corrupt[14+13:], 0xffffffff)
+
+	r, err = packet.CreateCaptureReader(bytes.NewReader(corrupt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Next(); err == nil || !strings.Contains(err.Error(), "некорректную длинну пакета") {
+		t.Fatal("Принята запись с некорректной длинной пакета", err)
+	}
+
+	if _, err := packet.CreateCaptureReader(bytes.NewReader([]byte("not a capture file"))); err == nil {
+		t.Fatal("Принят файл неизвестного формата")
+	}
+}
+
+func TestReadCaptureFileRawPackets(t *testing.T) {
+	// файлы без сигнатуры записи трафика, например resources/acp.r2pac, содержат только байты пакетов
+	data := append(append([]byte{}, resources.ACP_PACKET...), packet.CreatePacketOrPanic(5000, uint32(7)).Bytes()...)
+
+	if packet.IsCapture(data) {
+		t.Fatal("Байты пакетов приняты за запись трафика")
+	}
+
+	path := filepath.Join(t.TempDir(), "raw.r2pac")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := packet.ReadCaptureFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 || !bytes.Equal(records[0].Data, resources.ACP_PACKET) || records[1].ConnId != 0 {
+		t.Fatal("Неправильные записи из байтов пакетов", records)
+	}
+
+	// запись трафика по-прежнему читается по сигнатуре
+	w, err := packet.CreateCaptureFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WritePacket(packet.DIRECTION_CLIENT_TO_SERVER, w.NewConnId(), packet.CreatePacketOrPanic(5000))
+	w.Close()
+
+	if records, err := packet.ReadCaptureFile(path); err != nil || len(records) != 1 || records[0].ConnId != 1 {
+		t.Fatal("Неправильно прочитана запись трафика", records, err)
+	}
+}
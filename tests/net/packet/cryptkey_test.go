@@ -0,0 +1,115 @@
+package packet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+func TestCryptKeyRecovery(t *testing.T) {
+	current := packet.CurrentCryptKey()
+
+	p := packet.CreatePacketOrPanic(5000, uint32(7), [20]byte{1, 2, 3})
+	plain := packet.CreatePacketOrPanic(5000, uint32(7), [20]byte{1, 2, 3})
+	p.Encrypt()
+
+	key := packet.CreateCryptKey()
+
+	conflicts, err := key.AddPacket(p.Bytes(), plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conflicts) != 0 {
+		t.Fatalf("Неожиданные противоречия: %v", conflicts)
+	}
+
+	if key.Length() != len(p.Bytes()) || key.KnownPrefix() != key.Length() {
+		t.Fatalf("Длинна ключа [%v], известно с начала [%v], ожидалось [%v]", key.Length(), key.KnownPrefix(), len(p.Bytes()))
+	}
+
+	if !bytes.Equal(key.Bytes(), current.Bytes()[:key.Length()]) {
+		t.Fatalf("Восстановленный ключ %x не совпадает с текущим", key.Bytes())
+	}
+
+	// тот же пакет с другим содержимым противоречит ключу
+	wrong := packet.CreatePacketOrPanic(5000, uint32(8), [20]byte{1, 2, 3})
+
+	conflicts, err = key.AddPacket(p.Bytes(), wrong)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conflicts) != 1 || conflicts[0].Offset != 6 {
+		t.Fatalf("Ожидалось одно противоречие на смещении 6, получено: %v", conflicts)
+	}
+
+	if _, err := key.AddPacket(plain.Bytes(), plain); err == nil {
+		t.Fatal("Ожидалась ошибка для не зашифрованного пакета")
+	}
+}
+
+func TestCryptKeyPeriod(t *testing.T) {
+	original := packet.CurrentCryptKey()
+	defer packet.SetCryptKey(original)
+
+	// ключ с периодом 5, известный только на первые 12 байт данных
+	pad := []byte{0x11, 0x22, 0x33, 0x44, 0x55}
+	header := []byte{0, 0, 0, 0x8a, 0x7b, 0x65}
+	plain := append(append([]byte{}, header...), make([]byte, 12)...)
+	encrypted := append([]byte{}, plain...)
+
+	for i := range encrypted {
+		if i < len(header) {
+			encrypted[i] = plain[i] ^ header[i]
+		} else {
+			encrypted[i] = plain[i] ^ pad[(i-len(header))%len(pad)]
+		}
+	}
+
+	// флаг шифрования
+	plain[2] = 1
+	encrypted[2] = 1
+
+	key := packet.CreateCryptKey()
+
+	// байт данных 3 не известен
+	mask := make([]bool, len(plain))
+	for i := range mask {
+		mask[i] = i != len(header)+3
+	}
+
+	if _, err := key.AddKnownPlaintext(encrypted, plain, mask); err != nil {
+		t.Fatal(err)
+	}
+
+	if key.KnownPrefix() != len(header)+3 || key.Unknown() != 1 {
+		t.Fatalf("Известно с начала [%v], не известно [%v]", key.KnownPrefix(), key.Unknown())
+	}
+
+	if period := key.Period(5); period != len(pad) {
+		t.Fatalf("Найден период [%v], ожидался [%v]", period, len(pad))
+	}
+
+	if restored := key.ExtendPeriodic(len(pad), 40); restored != 29 {
+		t.Fatalf("Восстановлено байт [%v], ожидалось 29", restored)
+	}
+
+	packet.SetCryptKey(key)
+
+	data := make([]byte, 40)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	p := packet.CreatePacketOrPanic(5000, data)
+	p.Encrypt()
+	b := p.Bytes()
+
+	for i, c := range b[6:] {
+		if c != data[i]^pad[i%len(pad)] {
+			t.Fatalf("Байт [%v] зашифрован как %02x, ожидалось %02x", i, c, data[i]^pad[i%len(pad)])
+		}
+	}
+}
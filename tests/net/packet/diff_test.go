@@ -0,0 +1,150 @@
+package packet
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+func TestDiff(t *testing.T) {
+	registry := packet.CreateRegistry()
+	registry.RegisterOrPanic(5000, "TestList", packet.DIRECTION_SERVER_TO_CLIENT, testList{})
+
+	a, _ := packet.Marshal(testList{Owner: 1, Items: []testItem{{Id: 3}}})
+	b, _ := packet.Marshal(testList{Owner: 2, Items: []testItem{{Id: 3}, {Id: 4}}})
+
+	pa := packet.CreatePacketOrPanic(5000, a)
+	pb := packet.CreatePacketOrPanic(5000, b)
+
+	// пакеты сравниваются в расшифрованном виде
+	encrypted := packet.CreatePacketOrPanic(5000, a)
+	encrypted.Encrypt()
+
+	if diff := registry.Diff(pa, packet.CreatePacketOrPanic(5000, a)); !diff.Equal() {
+		t.Fatal("Одинаковые пакеты отличаются", diff)
+	}
+
+	if diff := registry.Diff(pa, encrypted); diff.Equal() || len(diff.Bytes) != 0 || len(diff.Headers) != 1 {
+		t.Fatal("Неправильное сравнение зашифрованного пакета", diff.Headers, diff.Bytes)
+	}
+
+	diff := registry.Diff(pa, pb)
+	if diff.Equal() || diff.Bytes[0] != 0 {
+		t.Fatal("Не найдены отличия", diff.Bytes)
+	}
+
+	paths := []string{"Owner", "Count", "Items[1].Id", "Items[1].Flags"}
+	if len(diff.Fields) != len(paths) {
+		t.Fatal("Неправильные отличия полей", diff.Fields)
+	}
+
+	for i, path := range paths {
+		if diff.Fields[i].Path != path {
+			t.Fatal("Неправильное поле", i, diff.Fields[i])
+		}
+	}
+
+	if diff.Fields[0].Old != "1" || diff.Fields[0].New != "2" || diff.Fields[2].Old != "" || diff.Fields[2].New != "4" {
+		t.Fatal("Неправильные значения полей", diff.Fields)
+	}
+
+	if s := diff.String(); !strings.Contains(s, "^^") || !strings.Contains(s, "Owner: 1 -> 2") {
+		t.Fatal("Неправильное представление отличий", s)
+	}
+}
+
+func TestDiffSequences(t *testing.T) {
+	registry := packet.CreateRegistry()
+
+	a := []*packet.Packet{
+		packet.CreatePacketOrPanic(1, uint8(1)),
+		packet.CreatePacketOrPanic(2, uint8(1)),
+		packet.CreatePacketOrPanic(3, uint8(1)),
+	}
+
+	b := []*packet.Packet{
+		packet.CreatePacketOrPanic(1, uint8(1)),
+		packet.CreatePacketOrPanic(4, uint8(1)),
+		packet.CreatePacketOrPanic(2, uint8(1)),
+		packet.CreatePacketOrPanic(3, uint8(2)),
+	}
+
+	diffs := registry.DiffSequences(a, b)
+
+	// лишний пакет 4 не сдвигает сравнение остальных
+	if len(diffs) != 4 || !diffs[0].Equal() || diffs[1].A != nil || diffs[1].B.Id != 4 || !diffs[2].Equal() || diffs[3].Equal() {
+		t.Fatal("Неправильное сопоставление пакетов", diffs)
+	}
+
+	if summary := diffs[3].Summary(); len(summary) != 1 || !strings.Contains(summary[0], "01 -> 02") {
+		t.Fatal("Неправильное описание отличий", summary)
+	}
+}
+
+// Длинна наибольшей общей подпоследовательности id пакетов по полной таблице
+func lcsLength(a []*packet.Packet, b []*packet.Packet) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i].Id == b[j].Id {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	return lcs[0][0]
+}
+
+func TestDiffSequencesRandom(t *testing.T) {
+	registry := packet.CreateRegistry()
+	rnd := rand.New(rand.NewSource(1))
+
+	randomSequence := func() []*packet.Packet {
+		list := make([]*packet.Packet, rnd.Intn(40))
+		for i := range list {
+			list[i] = packet.CreatePacketOrPanic(uint16(rnd.Intn(5)), uint8(i))
+		}
+		return list
+	}
+
+	for n := 0; n < 200; n++ {
+		a, b := randomSequence(), randomSequence()
+		diffs := registry.DiffSequences(a, b)
+
+		matched, ai, bi := 0, 0, 0
+		for _, d := range diffs {
+			if d.A != nil {
+				if d.A.Hex() != a[ai].Hex() {
+					t.Fatal("Нарушен порядок пакетов A")
+				}
+				ai++
+			}
+			if d.B != nil {
+				if d.B.Hex() != b[bi].Hex() {
+					t.Fatal("Нарушен порядок пакетов B")
+				}
+				bi++
+			}
+			if d.A != nil && d.B != nil {
+				if d.A.Id != d.B.Id {
+					t.Fatal("Сопоставлены пакеты с разными id", d.A.Id, d.B.Id)
+				}
+				matched++
+			}
+		}
+
+		if ai != len(a) || bi != len(b) || matched != lcsLength(a, b) {
+			t.Fatal("Сопоставлено не максимальное кол-во пакетов", matched, lcsLength(a, b))
+		}
+	}
+}
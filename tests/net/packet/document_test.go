@@ -0,0 +1,122 @@
+package packet
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDocument(t *testing.T) {
+	registry := packet.CreateRegistry()
+	registry.RegisterOrPanic(5000, "TestList", packet.DIRECTION_SERVER_TO_CLIENT, testList{})
+
+	src := testList{Owner: 7, Items: []testItem{{Id: 1, Flags: [2]byte{'o', 'k'}}, {Id: 2, Flags: [2]byte{1, 2}}}}
+	data, err := packet.Marshal(&src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := packet.CreatePacketOrPanic(5000, data)
+	p.Num = 3
+	p.Encrypt()
+
+	doc := p.Document(registry)
+
+	if doc.Name != "TestList" || !doc.Encrypted || doc.Fields == nil || doc.Data != "" {
+		t.Fatal("Неправильный документ пакета", doc)
+	}
+
+	b, err := yaml.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// поля идут в порядке описания, массивы байт похожие на строки представлены строками
+	if text := string(b); !strings.Contains(text, "Flags: ok") || strings.Index(text, "Owner") > strings.Index(text, "Items") {
+		t.Fatal("Неправильное представление пакета в YAML", text)
+	}
+
+	// документ, отредактированный вручную
+	edited := strings.Replace(string(b), "Owner: 7", "Owner: 8", 1)
+	doc = &packet.Document{}
+	if err := yaml.Unmarshal([]byte(edited), doc); err != nil {
+		t.Fatal(err)
+	}
+
+	p2, err := doc.Packet(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !p2.IsEncrypted() || p2.Num != 3 {
+		t.Fatal("Неправильные заголовки пакета", p2.Num, p2.IsEncrypted())
+	}
+
+	p2.Decrypt()
+	v, err := registry.Decode(p2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := v.(*testList)
+
+	if dst.Owner != 8 || len(dst.Items) != 2 || dst.Items[0].Flags != [2]byte{'o', 'k'} || dst.Items[1].Flags != [2]byte{1, 2} {
+		t.Fatal("Неправильные данные пакета", dst)
+	}
+
+	if _, err := (&packet.Document{Id: 5000, Fields: map[string]interface{}{"Unknown": 1}}).Packet(registry); err == nil {
+		t.Fatal("Принято неизвестное поле")
+	}
+}
+
+func TestDocumentRawData(t *testing.T) {
+	p := packet.CreatePacketOrPanic(5001, []byte{1, 2, 3})
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(b), `"data":"010203"`) {
+		t.Fatal("Неправильное представление пакета в JSON", string(b))
+	}
+
+	p2 := &packet.Packet{}
+	if err := json.Unmarshal([]byte(`{"id": 5001, "num": 1, "encrypted": false, "data": "01 02 03"}`), p2); err != nil {
+		t.Fatal(err)
+	}
+
+	if p2.Id != 5001 || p2.Num != 1 || p2.Hex() != "090000018913010203" {
+		t.Fatal("Неправильный пакет", p2.Hex())
+	}
+}
+
+func TestDocumentTrailingData(t *testing.T) {
+	registry := packet.CreateRegistry()
+	registry.RegisterOrPanic(5002, "TestItem", packet.DIRECTION_SERVER_TO_CLIENT, testItem{})
+
+	data, err := packet.Marshal(&testItem{Id: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// лишние байты после данных не попадают в поля, поэтому данные должны остаться hex-строкой
+	p := packet.CreatePacketOrPanic(5002, append(data, 0xAA, 0xBB))
+	doc := p.Document(registry)
+
+	if doc.Fields != nil || doc.Data == "" {
+		t.Fatal("Данные с лишними байтами представлены полями", doc)
+	}
+
+	p2, err := doc.Packet(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p2.Hex() != p.Hex() {
+		t.Fatal("Пакет изменился после преобразования в документ", p2.Hex(), p.Hex())
+	}
+}
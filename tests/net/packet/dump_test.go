@@ -0,0 +1,46 @@
+package packet
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+func TestDissect(t *testing.T) {
+	registry := packet.CreateRegistry()
+	registry.RegisterOrPanic(5000, "TestList", packet.DIRECTION_SERVER_TO_CLIENT, testList{})
+
+	b, _ := packet.Marshal(testList{Owner: 1, Items: []testItem{{Id: 3, Flags: [2]byte{'o', 'k'}}}})
+	b = append(b, 0xff)
+	p := packet.CreatePacketOrPanic(5000, b)
+
+	_, fields, err := packet.Dissect(p, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tail без тега забирает все оставшиеся данные, поэтому не разобранных данных нет
+	paths := []string{"Owner", "_", "Count", "Items[0].Id", "Items[0].Flags", "Tail[0]"}
+	if len(fields) != len(paths) {
+		t.Fatal("Неправильное кол-во полей", fields)
+	}
+
+	for i, path := range paths {
+		if fields[i].Path != path {
+			t.Fatal("Неправильный путь поля", i, fields[i].Path)
+		}
+	}
+
+	if fields[0].Offset != 6 || fields[3].Offset != 13 || fields[4].ValueString() != `"ok"` || fields[5].ValueString() != "255" {
+		t.Fatal("Неправильный разбор полей", fields[0].Offset, fields[3].Offset, fields[4].ValueString(), fields[5].ValueString())
+	}
+
+	if dump := p.Dump(registry); !strings.Contains(dump, "TestList(5000)") || !strings.Contains(dump, "Items[0].Flags") {
+		t.Fatal("Неправильный дамп пакета", dump)
+	}
+
+	if dump := p.Dump(packet.CreateRegistry()); !strings.Contains(dump, "ASCII") {
+		t.Fatal("Для неизвестного пакета должен выводиться hex-дамп", dump)
+	}
+}
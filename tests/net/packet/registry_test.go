@@ -0,0 +1,77 @@
+package packet
+
+import (
+	"testing"
+
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+type testItem struct {
+	Id    uint16
+	Flags [2]byte
+}
+
+type testList struct {
+	Owner uint32
+	_     [2]byte
+	Count uint8
+	Items []testItem `r2:"len=Count"`
+	Tail  []uint8
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	src := testList{
+		Owner: 7,
+		Items: []testItem{{Id: 1, Flags: [2]byte{1, 2}}, {Id: 2}},
+		Tail:  []uint8{9, 9},
+	}
+
+	b, err := packet.Marshal(&src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b) != 4+2+1+2*4+2 || b[6] != 2 {
+		t.Fatal("Неправильный результат кодирования", b)
+	}
+
+	dst := testList{}
+	if err := packet.Unmarshal(b, &dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if dst.Owner != 7 || dst.Count != 2 || len(dst.Items) != 2 || dst.Items[0].Flags[1] != 2 || len(dst.Tail) != 2 {
+		t.Fatal("Неправильный результат декодирования", dst)
+	}
+
+	if err := packet.Unmarshal(b[:8], &dst); err == nil {
+		t.Fatal("Декодированы неполные данные")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := packet.CreateRegistry()
+	registry.RegisterOrPanic(5000, "TestList", packet.DIRECTION_SERVER_TO_CLIENT, testList{})
+
+	if err := registry.Register(5000, "Duplicate", packet.DIRECTION_UNKNOWN, nil); err == nil {
+		t.Fatal("Пакет зарегистрирован повторно")
+	}
+
+	if name := registry.Name(5000); name != "TestList(5000)" {
+		t.Fatal("Неправильное название пакета", name)
+	}
+
+	if name := registry.Name(5001); name != "5001" {
+		t.Fatal("Неправильное название незарегистрированного пакета", name)
+	}
+
+	b, _ := packet.Marshal(testList{Items: []testItem{{Id: 3}}})
+	data, err := registry.Decode(packet.CreatePacketOrPanic(5000, b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if list := data.(*testList); len(list.Items) != 1 || list.Items[0].Id != 3 {
+		t.Fatal("Неправильный результат декодирования", list)
+	}
+}
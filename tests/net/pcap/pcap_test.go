@@ -0,0 +1,242 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+	"github.com/tuxuuman/r2o-core/pkg/net/pcap"
+)
+
+const SERVER_PORT = 11004
+
+type testFrame struct {
+	time time.Time
+	data []byte
+}
+
+// Собрать кадр Ethernet с IPv4 и TCP
+func tcpFrame(fromClient bool, seq uint32, flags uint8, payload []byte) []byte {
+	client := []byte{10, 0, 0, 2}
+	server := []byte{10, 0, 0, 1}
+	srcIp, dstIp, srcPort, dstPort := client, server, uint16(50000), uint16(SERVER_PORT)
+	if !fromClient {
+		srcIp, dstIp, srcPort, dstPort = server, client, SERVER_PORT, 50000
+	}
+
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp, srcPort)
+	binary.BigEndian.PutUint16(tcp[2:], dstPort)
+	binary.BigEndian.PutUint32(tcp[4:], seq)
+	tcp[12] = 5 << 4
+	tcp[13] = flags
+	tcp = append(tcp, payload...)
+
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+len(tcp)))
+	ip[8] = 64
+	ip[9] = 6
+	copy(ip[12:], srcIp)
+	copy(ip[16:], dstIp)
+
+	eth := make([]byte, 14)
+	binary.BigEndian.PutUint16(eth[12:], 0x0800)
+
+	return append(append(eth, ip...), tcp...)
+}
+
+func writePcap(frames []testFrame) []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, []uint32{0xa1b2c3d4})
+	binary.Write(buf, binary.LittleEndian, []uint16{2, 4})
+	binary.Write(buf, binary.LittleEndian, []uint32{0, 0, 65535, 1})
+
+	for _, f := range frames {
+		binary.Write(buf, binary.LittleEndian, []uint32{uint32(f.time.Unix()), uint32(f.time.Nanosecond() / 1000), uint32(len(f.data)), uint32(len(f.data))})
+		buf.Write(f.data)
+	}
+
+	return buf.Bytes()
+}
+
+func pcapngBlock(blockType uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, []uint32{blockType, uint32(12 + len(body))})
+	buf.Write(body)
+	binary.Write(buf, binary.BigEndian, uint32(12+len(body)))
+	return buf.Bytes()
+}
+
+func writePcapng(frames []testFrame) []byte {
+	buf := new(bytes.Buffer)
+
+	// секция в big-endian, чтобы проверить определение порядка байт
+	shb := new(bytes.Buffer)
+	binary.Write(shb, binary.BigEndian, uint32(0x1a2b3c4d))
+	binary.Write(shb, binary.BigEndian, []uint16{1, 0})
+	binary.Write(shb, binary.BigEndian, int64(-1))
+	buf.Write(pcapngBlock(0x0a0d0d0a, shb.Bytes()))
+
+	// интерфейс с метками времени в наносекундах
+	idb := new(bytes.Buffer)
+	binary.Write(idb, binary.BigEndian, []uint16{1, 0})
+	binary.Write(idb, binary.BigEndian, uint32(65535))
+	binary.Write(idb, binary.BigEndian, []uint16{9, 1})
+	idb.Write([]byte{9, 0, 0, 0})
+	binary.Write(idb, binary.BigEndian, []uint16{0, 0})
+	buf.Write(pcapngBlock(1, idb.Bytes()))
+
+	// блок, который должен быть пропущен
+	buf.Write(pcapngBlock(5, make([]byte, 8)))
+
+	for _, f := range frames {
+		ts := uint64(f.time.UnixNano())
+		epb := new(bytes.Buffer)
+		binary.Write(epb, binary.BigEndian, []uint32{0, uint32(ts >> 32), uint32(ts), uint32(len(f.data)), uint32(len(f.data))})
+		epb.Write(f.data)
+		buf.Write(pcapngBlock(6, epb.Bytes()))
+	}
+
+	return buf.Bytes()
+}
+
+func testSession() ([]testFrame, []*packet.Packet) {
+	acp := packet.CreatePacketOrPanic(1103, make([]byte, 10))
+
+	auth := packet.CreatePacketOrPanic(3100, []byte("login token data"))
+	auth.Encrypt()
+
+	list := packet.CreatePacketOrPanic(3102)
+	connect := packet.CreatePacketOrPanic(3103, uint32(7))
+
+	authBytes := auth.Bytes()
+	start := time.Unix(1700000000, 0)
+	at := func(ms int) time.Time {
+		return start.Add(time.Millisecond * time.Duration(ms))
+	}
+
+	frames := []testFrame{
+		{at(0), tcpFrame(true, 100, 0x02, nil)},
+		{at(1), tcpFrame(false, 500, 0x12, nil)},
+		{at(2), tcpFrame(false, 501, 0x18, acp.Bytes())},
+		// вторая часть пакета пришла раньше первой
+		{at(3), tcpFrame(true, 101+5, 0x18, authBytes[5:])},
+		{at(4), tcpFrame(true, 101, 0x18, authBytes[:5])},
+		// повторная передача
+		{at(5), tcpFrame(true, 101, 0x18, authBytes[:5])},
+		// обрезанный при записи кадр пропускается
+		{at(6), tcpFrame(true, 101, 0x18, nil)[:30]},
+		{at(7), tcpFrame(true, 101+uint32(len(authBytes)), 0x18, append(list.Bytes(), connect.Bytes()...))},
+	}
+
+	return frames, []*packet.Packet{acp, auth, list, connect}
+}
+
+func checkRecords(t *testing.T, records []packet.Record, packets []*packet.Packet) {
+	directions := []packet.Direction{packet.DIRECTION_SERVER_TO_CLIENT, packet.DIRECTION_CLIENT_TO_SERVER, packet.DIRECTION_CLIENT_TO_SERVER, packet.DIRECTION_CLIENT_TO_SERVER}
+
+	if len(records) != len(packets) {
+		t.Fatal("Неправильное кол-во пакетов", len(records))
+	}
+
+	for i, rec := range records {
+		if !bytes.Equal(rec.Data, packets[i].Bytes()) || rec.Direction != directions[i] || rec.ConnId != 1 {
+			t.Fatal("Неправильный пакет", i, rec)
+		}
+	}
+
+	// пакет собран в момент получения его первой части
+	if !records[1].Time.Equal(time.Unix(1700000000, 4000000)) {
+		t.Fatal("Неправильное время пакета", records[1].Time)
+	}
+
+	p, err := pcap.DecryptRecord(records[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 16)
+	if err := p.Read(data); err != nil || p.IsEncrypted() || string(data) != "login token data" {
+		t.Fatal("Пакет не расшифрован", string(data), err)
+	}
+}
+
+func TestExtractPcap(t *testing.T) {
+	frames, packets := testSession()
+
+	records, err := pcap.Extract(bytes.NewReader(writePcap(frames)), SERVER_PORT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRecords(t, records, packets)
+}
+
+func TestExtractPcapng(t *testing.T) {
+	frames, packets := testSession()
+
+	records, err := pcap.Extract(bytes.NewReader(writePcapng(frames)), SERVER_PORT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRecords(t, records, packets)
+}
+
+func TestExtractUnknownFormat(t *testing.T) {
+	if _, err := pcap.Extract(bytes.NewReader([]byte("not a pcap file")), SERVER_PORT); err == nil {
+		t.Fatal("Принят файл неизвестного формата")
+	}
+}
+
+func TestExtractOversizedFrame(t *testing.T) {
+	frames, _ := testSession()
+
+	// длинна кадра больше snaplen из заголовка файла
+	b := writePcap(frames[:1])
+	binary.LittleEndian.PutUint32(b[24+8:], 0xffffffff)
+
+	if _, err := pcap.Extract(bytes.NewReader(b), SERVER_PORT); err == nil {
+		t.Fatal("Принят кадр pcap длиннее snaplen")
+	}
+
+	// блок pcapng огромной длинны
+	b = append(writePcapng(nil), 0, 0, 0, 6, 0xff, 0xff, 0xff, 0xf0)
+
+	if _, err := pcap.Extract(bytes.NewReader(b), SERVER_PORT); err == nil {
+		t.Fatal("Принят блок pcapng огромной длинны")
+	}
+}
+
+func TestExtractPackets(t *testing.T) {
+	frames, expected := testSession()
+
+	packets, err := pcap.ExtractPackets(bytes.NewReader(writePcap(frames)), SERVER_PORT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(packets) != len(expected) {
+		t.Fatal("Неправильное кол-во пакетов", len(packets))
+	}
+
+	for i, p := range packets {
+		decrypted := packet.CreatePacketFromBytesOrPanic(expected[i].Bytes())
+		decrypted.Decrypt()
+
+		if p.IsEncrypted() || p.Hex() != decrypted.Hex() {
+			t.Fatal("Пакет не расшифрован", i, p.Hex())
+		}
+	}
+
+	// пакет авторизации передавался зашифрованным
+	if !expected[1].IsEncrypted() || string(packets[1].Bytes()[6:]) != "login token data" {
+		t.Fatal("Неправильные данные расшифрованного пакета", packets[1].Hex())
+	}
+}
@@ -0,0 +1,105 @@
+package net
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/net"
+)
+
+func TestQueueOrder(t *testing.T) {
+	positions := make(chan uint16, 10)
+
+	server := startServer(t, 18114, func(s *net.Server) {
+		s.MaxClientsCount = 1
+		s.MaxQueueLength = 2
+		s.OnQueuePositionChanged(func(c *net.Client, position uint16) {
+			positions <- position
+		}, false)
+	}, nil)
+	defer server.Stop()
+
+	first, p := connect(t, 18114)
+	defer first.Close()
+	expectAccepted(t, p)
+
+	second := dial(t, 18114)
+	defer second.Close()
+	waitFor(t, func() bool { return server.GetQueueLength() == 1 }, "Второй клиент не поставлен в очередь")
+
+	third := dial(t, 18114)
+	defer third.Close()
+	waitFor(t, func() bool { return server.GetQueueLength() == 2 }, "Третий клиент не поставлен в очередь")
+
+	// очередь заполнена
+	overflow, p := connect(t, 18114)
+	defer overflow.Close()
+	expectRejected(t, p, net.ERROR_SERVER_IS_FULL)
+
+	first.Close()
+	expectAccepted(t, read(t, second))
+
+	second.Close()
+	expectAccepted(t, read(t, third))
+
+	// позиции: второй 1, третий 2, после подключения второго третий 1
+	for _, expected := range []uint16{1, 2, 1} {
+		if position := <-positions; position != expected {
+			t.Fatal("Неправильная позиция в очереди", position, expected)
+		}
+	}
+}
+
+func TestQueueTimeout(t *testing.T) {
+	server := startServer(t, 18115, func(s *net.Server) {
+		s.MaxClientsCount = 1
+		s.MaxQueueLength = 1
+		s.MaxQueueWaitTimeout = 1
+	}, nil)
+	defer server.Stop()
+
+	first, p := connect(t, 18115)
+	defer first.Close()
+	expectAccepted(t, p)
+
+	queued := dial(t, 18115)
+	defer queued.Close()
+
+	started := time.Now()
+	expectRejected(t, read(t, queued), net.ERROR_SERVER_IS_FULL)
+
+	if waited := time.Since(started); waited < time.Millisecond*500 {
+		t.Fatal("Клиент отклонен раньше времени", waited)
+	}
+
+	if server.GetQueueLength() != 0 {
+		t.Fatal("Клиент остался в очереди")
+	}
+}
+
+func TestQueueDisconnect(t *testing.T) {
+	server := startServer(t, 18116, func(s *net.Server) {
+		s.MaxClientsCount = 1
+		s.MaxQueueLength = 2
+	}, nil)
+	defer server.Stop()
+
+	first, p := connect(t, 18116)
+	defer first.Close()
+	expectAccepted(t, p)
+
+	gone := dial(t, 18116)
+	waitFor(t, func() bool { return server.GetQueueLength() == 1 }, "Клиент не поставлен в очередь")
+
+	waiting := dial(t, 18116)
+	defer waiting.Close()
+	waitFor(t, func() bool { return server.GetQueueLength() == 2 }, "Клиент не поставлен в очередь")
+
+	// отключившийся клиент сразу убирается из очереди и освобождает место
+	gone.Close()
+	waitFor(t, func() bool { return server.GetQueueLength() == 1 }, "Отключившийся клиент остался в очереди")
+	waitFor(t, func() bool { return server.GetIPConnectionsCount("127.0.0.1") == 2 }, "Место подключения не освободилось")
+
+	first.Close()
+	expectAccepted(t, read(t, waiting))
+}
@@ -0,0 +1,230 @@
+package net
+
+import (
+	"fmt"
+	gonet "net"
+	"testing"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/net"
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+)
+
+// Запустить сервер на "port", принимающий все зарегистрированные подключения. Сервер нужно остановить через Stop.
+//
+// "configure" - необязательная настройка сервера до запуска
+//
+// "onConnection" - необязательная настройка клиента до того, как его подключение будет принято
+func startServer(t *testing.T, port uint16, configure func(s *net.Server), onConnection func(s *net.Server, c *net.Client)) *net.Server {
+	t.Helper()
+
+	server := net.CreateServer("127.0.0.1", port)
+	if configure != nil {
+		configure(&server)
+	}
+	go server.Start(func(c *net.Client) {
+		if onConnection != nil {
+			onConnection(&server, c)
+		}
+		c.Accept()
+	})
+	waitFor(t, server.IsStarted, "Сервер не запущен")
+	return &server
+}
+
+// Подключиться к серверу, не дожидаясь ответа
+func dial(t *testing.T, port uint16) gonet.Conn {
+	conn, err := gonet.Dial("tcp", fmt.Sprintf("127.0.0.1:%v", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// Прочитать пакет от сервера
+func read(t *testing.T, conn gonet.Conn) *packet.Packet {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+
+	p, err := packet.ReadPacket(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.Decrypt()
+	return p
+}
+
+// Подключиться к серверу и прочитать первый пакет: разрешение подключения или критическую ошибку
+func connect(t *testing.T, port uint16) (gonet.Conn, *packet.Packet) {
+	conn := dial(t, port)
+	return conn, read(t, conn)
+}
+
+// Проверить, что подключение отклонено с ошибкой "errorId"
+func expectRejected(t *testing.T, p *packet.Packet, errorId uint32) {
+	t.Helper()
+
+	if p.Id != net.PACKET_FATAL_ERROR {
+		t.Fatalf("Ожидалось отклонение подключения, получен пакет [%v]", p.Id)
+	}
+
+	fatal := net.FatalErrorPacket{}
+	if err := p.Read(&fatal); err != nil {
+		t.Fatal(err)
+	}
+
+	if fatal.ErrorId != errorId {
+		t.Fatalf("Подключение отклонено с ошибкой [%v], ожидалась [%v]", fatal.ErrorId, errorId)
+	}
+}
+
+func expectAccepted(t *testing.T, p *packet.Packet) {
+	t.Helper()
+
+	if p.Id != net.PACKET_ACCEPT_CONNECTION {
+		t.Fatalf("Ожидалось разрешение подключения, получен пакет [%v]", p.Id)
+	}
+}
+
+// Ждать, пока "cond" не станет true
+func waitFor(t *testing.T, cond func() bool, message string) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second * 5)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestMaxConnectionsPerIP(t *testing.T) {
+	limits := make(chan string, 10)
+
+	server := startServer(t, 18111, func(s *net.Server) {
+		s.MaxConnectionsPerIP = 2
+		s.ConnectionLimitErrorId = 42
+		s.OnLimitExceeded(func(ip string, limit string) {
+			if ip == "127.0.0.1" {
+				limits <- limit
+			}
+		}, false)
+	}, nil)
+	defer server.Stop()
+
+	first, p := connect(t, 18111)
+	defer first.Close()
+	expectAccepted(t, p)
+
+	second, p := connect(t, 18111)
+	defer second.Close()
+	expectAccepted(t, p)
+
+	third, p := connect(t, 18111)
+	defer third.Close()
+	expectRejected(t, p, 42)
+
+	select {
+	case limit := <-limits:
+		if limit != net.LIMIT_CONNECTIONS_PER_IP {
+			t.Fatal("Сработало не то ограничение", limit)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Не вызван OnLimitExceeded")
+	}
+
+	if count := server.GetIPConnectionsCount("127.0.0.1"); count != 2 {
+		t.Fatal("Неправильное кол-во подключений с IP", count)
+	}
+
+	// после отключения клиента место освобождается
+	first.Close()
+	waitFor(t, func() bool { return server.GetIPConnectionsCount("127.0.0.1") == 1 }, "Место подключения не освободилось")
+
+	fourth, p := connect(t, 18111)
+	defer fourth.Close()
+	expectAccepted(t, p)
+}
+
+func TestConnectionRate(t *testing.T) {
+	limits := make(chan string, 10)
+
+	server := startServer(t, 18112, func(s *net.Server) {
+		s.ConnectionRate = 0.5
+		s.ConnectionBurst = 2
+		s.OnLimitExceeded(func(ip string, limit string) {
+			limits <- limit
+		}, false)
+	}, nil)
+	defer server.Stop()
+
+	for i := 0; i < 2; i++ {
+		conn, p := connect(t, 18112)
+		expectAccepted(t, p)
+		conn.Close()
+	}
+
+	conn, p := connect(t, 18112)
+	defer conn.Close()
+	expectRejected(t, p, net.ERROR_SERVER_IS_FULL)
+
+	select {
+	case limit := <-limits:
+		if limit != net.LIMIT_CONNECTION_RATE {
+			t.Fatal("Сработало не то ограничение", limit)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Не вызван OnLimitExceeded")
+	}
+}
+
+func TestAdmitFunc(t *testing.T) {
+	// кол-во клиентов сервера при вызове OnConnection
+	connections := make(chan uint16, 10)
+	admitted := make(chan string, 10)
+
+	server := startServer(t, 18121, func(s *net.Server) {
+		s.AddAdmitFunc(func(remoteAddr gonet.Addr) (bool, uint32) {
+			admitted <- remoteAddr.String()
+			return true, 0
+		})
+		// отклоняет каждое второе подключение
+		count := 0
+		s.AddAdmitFunc(func(remoteAddr gonet.Addr) (bool, uint32) {
+			count++
+			return count%2 == 0, 99
+		})
+	}, func(s *net.Server, c *net.Client) {
+		// OnConnection вызывается в основном цикле сервера, поэтому кол-во клиентов можно прочитать только здесь
+		connections <- s.GetClientsCount()
+	})
+	defer server.Stop()
+
+	rejected, p := connect(t, 18121)
+	defer rejected.Close()
+	expectRejected(t, p, 99)
+
+	if len(connections) != 0 {
+		t.Fatal("Отклоненное подключение передано в OnConnection")
+	}
+
+	accepted, p := connect(t, 18121)
+	defer accepted.Close()
+	expectAccepted(t, p)
+
+	select {
+	case count := <-connections:
+		if count != 1 {
+			t.Fatal("Отклоненный клиент учтен в кол-ве клиентов", count)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Допущенное подключение не передано в OnConnection")
+	}
+
+	if len(admitted) != 2 {
+		t.Fatal("Функция допуска вызвана не для каждого подключения", len(admitted))
+	}
+}
@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"encoding/binary"
+	"io"
+	gonet "net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/login"
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+	"github.com/tuxuuman/r2o-core/pkg/proxy"
+)
+
+func readPacket(t *testing.T, conn gonet.Conn) *packet.Packet {
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+
+	bufLen := make([]byte, 2)
+	if _, err := io.ReadFull(conn, bufLen); err != nil {
+		t.Fatal(err)
+	}
+
+	bufPac := make([]byte, binary.LittleEndian.Uint16(bufLen)-2)
+	if _, err := io.ReadFull(conn, bufPac); err != nil {
+		t.Fatal(err)
+	}
+
+	return packet.CreatePacketFromBytesOrPanic(append(bufLen, bufPac...))
+}
+
+func TestProxy(t *testing.T) {
+	servers := login.StaticDirectory{
+		{Id: 1, Online: true, ListId: 1, Ip: [4]byte{127, 0, 0, 1}, Port: 11005, Name: login.MakeServerName("Server 1")},
+	}
+
+	server := login.CreateServer("127.0.0.1", 18107, login.CreateStaticAuthenticator(map[string]uint32{"qwerty": 7}), servers)
+	go server.Start()
+	defer server.Stop()
+
+	capturePath := filepath.Join(t.TempDir(), "proxy.r2pac")
+	capture, err := packet.CreateCaptureFile(capturePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer capture.Close()
+
+	px := proxy.CreateProxy("127.0.0.1:18108", "127.0.0.1:18107")
+	px.Capture = capture
+
+	// подменяем токен авторизации
+	px.AddHook(func(s *proxy.Session, direction packet.Direction, p *packet.Packet) *packet.Packet {
+		if direction != packet.DIRECTION_CLIENT_TO_SERVER || p.Id != login.PACKET_AUTH_REQUEST {
+			return p
+		}
+		req := login.AuthRequest{}
+		copy(req.P4[:], "qwerty")
+		return packet.CreatePacketOrPanic(login.PACKET_AUTH_REQUEST, &req)
+	})
+
+	// вместо запроса списка серверов отвечаем клиенту сами
+	px.AddHook(func(s *proxy.Session, direction packet.Direction, p *packet.Packet) *packet.Packet {
+		if p.Id != login.PACKET_SERVER_LIST_REQUEST {
+			return p
+		}
+		res := login.ServerList{}
+		list, _ := res.Packet()
+		s.SendToClient(list)
+		return nil
+	})
+
+	go px.Listen()
+	defer px.Close()
+	time.Sleep(time.Millisecond * 100)
+
+	conn, err := gonet.Dial("tcp", "127.0.0.1:18108")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if p := readPacket(t, conn); p.Id != 1103 {
+		t.Fatal("Ожидался пакет разрешения подключения", p.Id)
+	}
+
+	authReq := login.AuthRequest{}
+	copy(authReq.P4[:], "wrong")
+	p := packet.CreatePacketOrPanic(login.PACKET_AUTH_REQUEST, &authReq)
+	p.Encrypt()
+	conn.Write(p.Bytes())
+
+	p = readPacket(t, conn)
+	p.Decrypt()
+	var accountId uint32
+	if p.Id != login.PACKET_AUTH_RESULT || p.Read(&accountId) != nil || accountId != 7 {
+		t.Fatal("Ожидался результат авторизации с подмененным токеном", p.Id, accountId)
+	}
+
+	conn.Write(packet.CreatePacketOrPanic(login.PACKET_SERVER_LIST_REQUEST).Bytes())
+
+	p = readPacket(t, conn)
+	p.Decrypt()
+	var count uint8
+	if p.Id != login.PACKET_SERVER_LIST || p.Read(&count) != nil || count != 0 {
+		t.Fatal("Ожидался список серверов от прокси", p.Id, count)
+	}
+
+	records, err := packet.ReadCaptureFile(capturePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1103, запрос авторизации, результат авторизации и список серверов. Запрос списка серверов отброшен
+	ids := []uint16{1103, login.PACKET_AUTH_REQUEST, login.PACKET_AUTH_RESULT, login.PACKET_SERVER_LIST}
+	if len(records) != len(ids) {
+		t.Fatal("Неправильное кол-во записанных пакетов", len(records))
+	}
+
+	for i, rec := range records {
+		if p, _ := rec.Packet(); p.Id != ids[i] {
+			t.Fatal("Неправильный записанный пакет", i, p.Id)
+		}
+	}
+
+	// подмененный пакет зашифрован, как и исходный
+	if p, _ := records[1].Packet(); !p.IsEncrypted() {
+		t.Fatal("Подмененный пакет отправлен без шифрования")
+	}
+}
@@ -0,0 +1,39 @@
+package r2cfg
+
+import (
+	"testing"
+
+	"github.com/tuxuuman/r2o-core/pkg/r2cfg"
+)
+
+const ETALON_CONFIG = "; настройки подключения\r\nchannelserverip = 1.2.3.4\r\nChannelServerPort=11004  \r\n\r\nwindowmode = 1\r\n"
+
+func TestSetLoginServer(t *testing.T) {
+	config := r2cfg.Parse([]byte(ETALON_CONFIG))
+
+	if ip, _ := config.Get("ChannelServerIp"); ip != "1.2.3.4" {
+		t.Fatal("Неправильное значение", ip)
+	}
+
+	config.SetLoginServer("127.0.0.1", 11005)
+
+	expected := "; настройки подключения\r\nchannelserverip = 127.0.0.1\r\nChannelServerPort=11005  \r\n\r\nwindowmode = 1\r\n"
+
+	if string(config.Bytes()) != expected {
+		t.Fatalf("Неправильный результат. Ожидаемый результат: %q. Полученый результат: %q", expected, config.Bytes())
+	}
+}
+
+func TestUnchanged(t *testing.T) {
+	config := r2cfg.Parse([]byte(ETALON_CONFIG))
+
+	if string(config.Bytes()) != ETALON_CONFIG {
+		t.Fatalf("Файл изменился без изменения параметров: %q", config.Bytes())
+	}
+
+	config.Set("newkey", "value")
+
+	if keys := config.Keys(); len(keys) != 4 || keys[3] != "newkey" {
+		t.Fatal("Неправильный список ключей", keys)
+	}
+}
@@ -0,0 +1,148 @@
+package replay
+
+import (
+	"encoding/binary"
+	"io"
+	gonet "net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/login"
+	"github.com/tuxuuman/r2o-core/pkg/net/packet"
+	"github.com/tuxuuman/r2o-core/pkg/replay"
+)
+
+const SERVER_ADDRESS = "127.0.0.1:18106"
+
+func readPacket(t *testing.T, conn gonet.Conn) {
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+
+	bufLen := make([]byte, 2)
+	if _, err := io.ReadFull(conn, bufLen); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.ReadFull(conn, make([]byte, binary.LittleEndian.Uint16(bufLen)-2)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Записать сессию клиента: авторизация и запрос списка серверов
+func recordSession(t *testing.T) {
+	conn, err := gonet.Dial("tcp", SERVER_ADDRESS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	readPacket(t, conn)
+
+	authReq := login.AuthRequest{}
+	copy(authReq.P4[:], "qwerty")
+	p := packet.CreatePacketOrPanic(login.PACKET_AUTH_REQUEST, &authReq)
+	p.Encrypt()
+	conn.Write(p.Bytes())
+	readPacket(t, conn)
+
+	conn.Write(packet.CreatePacketOrPanic(login.PACKET_SERVER_LIST_REQUEST).Bytes())
+	readPacket(t, conn)
+}
+
+func TestReplay(t *testing.T) {
+	servers := login.StaticDirectory{
+		{Id: 1, Online: true, ListId: 1, Ip: [4]byte{127, 0, 0, 1}, Port: 11005, Name: login.MakeServerName("Server 1")},
+	}
+
+	accountId := uint32(7)
+	auth := login.AuthenticatorFunc(func(token string, ip string) (uint32, uint32, uint32) {
+		return accountId, 123456, 0
+	})
+
+	capturePath := filepath.Join(t.TempDir(), "session.r2pac")
+	capture, err := packet.CreateCaptureFile(capturePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer capture.Close()
+
+	server := login.CreateServer("127.0.0.1", 18106, auth, servers)
+	server.Capture = capture
+	go server.Start()
+	defer server.Stop()
+	time.Sleep(time.Millisecond * 100)
+
+	recordSession(t)
+
+	records, err := packet.ReadCaptureFile(capturePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// повтор пишется в ту же запись под другим id соединения, поэтому воспроизводим только первое
+	options := replay.DefaultOptions()
+	options.ConnId = records[0].ConnId
+	options.TimeScale = 0.5
+
+	report, err := replay.Run(SERVER_ADDRESS, records, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.OK() || report.Sent != 2 || len(report.Responses) != 3 {
+		t.Fatal("Ответы сервера не совпали с записью", report)
+	}
+
+	accountId = 8
+
+	report, err = replay.Run(SERVER_ADDRESS, records, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.OK() || len(report.Responses[1].Diff) == 0 || len(report.Responses[2].Diff) != 0 {
+		t.Fatal("Не найдено отличие в ответе на авторизацию", report)
+	}
+
+	if !strings.Contains(report.String(), "Ответ #2") {
+		t.Fatal("Неправильный отчет", report)
+	}
+	// сервер ответил пакетом другого типа: отличия полей не сравниваются
+	accountId = 7
+	last := -1
+	for i, rec := range records {
+		if rec.ConnId == options.ConnId && rec.Direction == packet.DIRECTION_SERVER_TO_CLIENT {
+			last = i
+		}
+	}
+
+	changed := append([]packet.Record{}, records...)
+	changed[last].Data = append([]byte{}, records[last].Data...)
+	binary.LittleEndian.PutUint16(changed[last].Data[4:], login.PACKET_AUTH_REQUEST)
+
+	report, err = replay.Run(SERVER_ADDRESS, changed, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := report.Responses[2].Diff; len(diff) != 1 || !strings.HasPrefix(diff[0], "Ожидался пакет") {
+		t.Fatal("Неправильное отличие пакетов разных типов", diff)
+	}
+	// сервер отправил на один ответ меньше, чем в записи
+	missing := append(append([]packet.Record{}, records...), records[last])
+	options.ResponseTimeout = time.Millisecond * 500
+
+	report, err = replay.Run(SERVER_ADDRESS, missing, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Responses) != 4 || report.Responses[3].Actual != nil {
+		t.Fatal("Не найден пропущенный ответ", report)
+	}
+
+	if diff := report.Responses[3].Diff; len(diff) != 1 || !strings.HasPrefix(diff[0], "Нет пакета") {
+		t.Fatal("Неправильное отличие для пропущенного ответа", diff)
+	}
+}
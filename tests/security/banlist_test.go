@@ -0,0 +1,97 @@
+package security
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/security"
+)
+
+func TestBanListFind(t *testing.T) {
+	banList, err := security.CreateBanList(filepath.Join(t.TempDir(), "bans.json"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := banList.Ban("10.0.0.0/8", 0, "spam", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := banList.Ban("127.0.0.1", time.Hour, "bot", "проверить позже"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !banList.IsBanned(net.ParseIP("10.1.2.3")) {
+		t.Fatal("IP из заблокированной подсети не заблокирован")
+	}
+
+	if !banList.IsBanned(net.ParseIP("127.0.0.1")) {
+		t.Fatal("Заблокированный IP не заблокирован")
+	}
+
+	if banList.IsBanned(net.ParseIP("127.0.0.2")) {
+		t.Fatal("Заблокирован лишний IP")
+	}
+
+	if allow, errorId := banList.Admit(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}); allow || errorId != security.ERROR_IP_BLOCKED {
+		t.Fatal("Подключение с заблокированного IP допущено", allow, errorId)
+	}
+
+	if removed, err := banList.Unban("127.0.0.1"); err != nil || !removed {
+		t.Fatal("Не удалось разблокировать IP", removed, err)
+	}
+
+	if banList.IsBanned(net.ParseIP("127.0.0.1")) {
+		t.Fatal("Разблокированный IP все еще заблокирован")
+	}
+}
+
+func TestBanListExpiry(t *testing.T) {
+	banList, err := security.CreateBanList(filepath.Join(t.TempDir(), "bans.json"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := banList.Ban("192.168.0.1", time.Millisecond, "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond * 5)
+
+	if banList.IsBanned(net.ParseIP("192.168.0.1")) {
+		t.Fatal("Истекшая блокировка все еще действует")
+	}
+}
+
+func TestBanListPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	banList, err := security.CreateBanList(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := banList.Ban("172.16.0.0/12", 0, "proxy", "note"); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := security.CreateBanList(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bans := loaded.List()
+
+	if len(bans) != 1 || bans[0].Target != "172.16.0.0/12" || bans[0].Reason != "proxy" || bans[0].Note != "note" {
+		t.Fatal("Бан-лист загружен неправильно", bans)
+	}
+
+	if !loaded.IsBanned(net.ParseIP("172.16.5.5")) {
+		t.Fatal("Загруженная блокировка не действует")
+	}
+}
@@ -0,0 +1,83 @@
+package session
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/session"
+)
+
+func testStore(t *testing.T, store session.Store) {
+	s, err := store.Issue(7, "127.0.0.1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Id == 0 || s.AccountId != 7 {
+		t.Fatal("Неправильная сессия", s)
+	}
+
+	if _, err := store.Consume(s.Id, "127.0.0.1", 1); err != session.ErrNotBound {
+		t.Fatal("Использована сессия без выбранного сервера", err)
+	}
+
+	if err := store.Bind(s.Id, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Consume(s.Id, "127.0.0.2", 1); err != session.ErrWrongIP {
+		t.Fatal("Сессия использована с чужого IP", err)
+	}
+
+	if _, err := store.Consume(s.Id, "127.0.0.1", 2); err != session.ErrWrongServer {
+		t.Fatal("Сессия использована на другом сервере", err)
+	}
+
+	consumed, err := store.Consume(s.Id, "127.0.0.1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if consumed.AccountId != 7 {
+		t.Fatal("Неправильный id аккаунта", consumed.AccountId)
+	}
+
+	if _, err := store.Consume(s.Id, "127.0.0.1", 1); err != session.ErrNotFound {
+		t.Fatal("Сессия использована повторно", err)
+	}
+
+	expired, err := store.Issue(8, "127.0.0.1", -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// просроченная сессия может быть уже удалена хранилищем
+	if err := store.Bind(expired.Id, 1); err != session.ErrExpired && err != session.ErrNotFound {
+		t.Fatal("Просроченная сессия привязана к серверу", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, session.CreateMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	testStore(t, session.CreateFileStore(path))
+
+	// сессия выданная одним процессом, должна быть доступна другому
+	s, err := session.CreateFileStore(path).Issue(1, "127.0.0.1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := session.CreateFileStore(path)
+	if err := other.Bind(s.Id, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := other.Consume(s.Id, "127.0.0.1", 1); err != nil {
+		t.Fatal(err)
+	}
+}
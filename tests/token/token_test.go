@@ -0,0 +1,70 @@
+package token
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tuxuuman/r2o-core/pkg/token"
+)
+
+var SECRET = []byte("secret")
+
+func TestIssueVerify(t *testing.T) {
+	issuer := token.CreateIssuer(SECRET)
+	verifier := token.CreateVerifier(SECRET)
+
+	tok, err := issuer.Issue(42, time.Minute, net.ParseIP("127.0.0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tok) > token.MAX_LENGTH {
+		t.Fatal("Токен слишком длинный", len(tok))
+	}
+
+	if _, err := verifier.Verify(tok, "127.0.0.2"); err != token.ErrWrongIP {
+		t.Fatal("Токен принят с чужого IP", err)
+	}
+
+	claims, err := verifier.Verify(tok, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.AccountId != 42 {
+		t.Fatal("Неправильный id аккаунта", claims.AccountId)
+	}
+
+	if _, err := verifier.Verify(tok, "127.0.0.1"); err != token.ErrReusedToken {
+		t.Fatal("Токен принят повторно", err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	issuer := token.CreateIssuer(SECRET)
+	verifier := token.CreateVerifier(SECRET)
+	verifier.ClockSkew = 0
+
+	expired, err := issuer.Issue(1, -time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := verifier.Verify(expired, "127.0.0.1"); err != token.ErrExpiredToken {
+		t.Fatal("Принят просроченный токен", err)
+	}
+
+	foreign, err := token.CreateIssuer([]byte("other")).Issue(1, time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := verifier.Verify(foreign, "127.0.0.1"); err != token.ErrInvalidSign {
+		t.Fatal("Принят токен с чужой подписью", err)
+	}
+
+	if _, err := verifier.Verify("qwerty", "127.0.0.1"); err == nil {
+		t.Fatal("Принят некорректный токен")
+	}
+}